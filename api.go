@@ -55,6 +55,21 @@ type Element interface {
 	Less(other Element) bool
 }
 
+// A Tombstone is an Element that records the deletion of the elements that are equal
+// to it. When a Tombstone is merged with an older, equal element, the Tombstone is kept,
+// regardless of the Resolver in use. A Tombstone is itself hidden from readers by
+// the ranges returned by Visible.
+//
+// An Element type may implement Tombstone in order to support deletes. Since
+// Element.Less implementations usually expect their argument to have the same type
+// as the receiver, a tombstone is typically represented by an Element of the usual
+// type with a flag set.
+type Tombstone interface {
+	Element
+	// IsTombstone answers true if the receiver marks a deletion.
+	IsTombstone() bool
+}
+
 // Elements are slices of Element
 type Elements []Element
 
@@ -98,6 +113,16 @@ type Cursor interface {
 	Fill(buffer []Element) int
 }
 
+// A SeekableCursor is a Cursor that can skip forward over the elements of a range
+// without reading them. The cursors opened by the Open methods of this package
+// are SeekableCursors; Seekable adapts any other Cursor.
+type SeekableCursor interface {
+	Cursor
+	// Seek advances the cursor so that the next element it answers is the first
+	// remaining element that is not less than e. Seek never moves a cursor backwards.
+	Seek(e Element)
+}
+
 // A SortedRange is a Range that can provide a Cursor that performs a sorted, deduplicated
 // iteration over the contents of a SortedRange. A SortedRange can also be partitioned
 // into a pair of (possibly empty) sub-ranges which are also sorted, the concatenation of
@@ -112,6 +137,76 @@ type SortedRange interface {
 	Partition(e Element, o Order) (SortedRange, SortedRange)
 }
 
+// A ReversibleRange is a SortedRange that can iterate over its elements in descending order.
+// The SortedRanges of this package are ReversibleRanges.
+type ReversibleRange interface {
+	SortedRange
+	// OpenReverse opens a cursor that iterates over the deduplicated elements of the
+	// receiver in descending order.
+	OpenReverse() Cursor
+}
+
+// OpenReverse opens a cursor that iterates over the elements of r in descending order.
+// If r is not a ReversibleRange, its elements are first copied into a slice.
+func OpenReverse(r SortedRange) Cursor {
+	if rr, ok := r.(ReversibleRange); ok {
+		return rr.OpenReverse()
+	}
+	elements := AsSlice(r)
+	return &reverseCursor{
+		next:     len(elements),
+		elements: elements,
+	}
+}
+
+// A Counter is a Range that can answer the exact number of elements it contains.
+// The SortedRanges of this package are Counters.
+type Counter interface {
+	Range
+	// Count answers the number of deduplicated elements in the receiver. Unlike Limit,
+	// Count is exact, but it may need to complete a merge that is still pending.
+	Count() int
+}
+
+// Count answers the exact number of elements in r. If r is not a Counter, its elements
+// are counted with a cursor.
+func Count(r SortedRange) int {
+	if c, ok := r.(Counter); ok {
+		return c.Count()
+	}
+	count := 0
+	buffer := make([]Element, 256)
+	c := r.Open()
+	for n := c.Fill(buffer); n > 0; n = c.Fill(buffer) {
+		count += n
+	}
+	return count
+}
+
+// A Getter is a SortedRange that can look up the element equal to a key without
+// iterating over the range. The SortedRanges of this package are Getters.
+type Getter interface {
+	SortedRange
+	// Get answers the element of the receiver that is equal to key and true, or
+	// nil and false if there is no such element. The answered element is the one that
+	// a cursor over the receiver would answer, which may be a Tombstone.
+	Get(key Element) (Element, bool)
+}
+
+// Get answers the element of r that is equal to key and true, or nil and false if
+// there is no such element. If r is not a Getter, a cursor over r is seeked to key.
+func Get(r SortedRange, key Element) (Element, bool) {
+	if g, ok := r.(Getter); ok {
+		return g.Get(key)
+	}
+	c := Seekable(r.Open())
+	c.Seek(key)
+	if e := c.Next(); e != nil && !key.Less(e) {
+		return e, true
+	}
+	return nil, false
+}
+
 // AsSlice converts a SortedRange into a slice.
 func AsSlice(r SortedRange) []Element {
 	result := make([]Element, r.Limit(), r.Limit())
@@ -121,11 +216,54 @@ func AsSlice(r SortedRange) []Element {
 	return result
 }
 
+// A Resolver combines two equal elements into a single element when a range is
+// deduplicated. A Resolver is not consulted if either element is a Tombstone: a newer
+// Tombstone always replaces an older element and a newer element always replaces an
+// older Tombstone. The older element is the one that was added to a range first or that
+// came from the earlier of two merged ranges. The element answered by a Resolver must
+// be equal to both of its arguments.
+//
+// A Resolver may answer a new element, for example to sum two values recorded at the
+// same time. In that case the First() and Last() elements of a range may be equal to,
+// but not identical with, the first and last elements read by a cursor.
+type Resolver func(older Element, newer Element) Element
+
+// LastWins is the default Resolver. It answers the newer of two equal elements.
+func LastWins(older Element, newer Element) Element {
+	return newer
+}
+
+// KeepFirst is a Resolver that answers the older of two equal elements.
+func KeepFirst(older Element, newer Element) Element {
+	return older
+}
+
 // Merge merges two SortedRange to produce a third SortedRange which represents, the
 // merged, deduplicated merge of the two original ranges. Where two elements from a and
 // b are equal, the resulting SortedRange contains the element from b.
 func Merge(a SortedRange, b SortedRange) SortedRange {
-	return merge(a, b)
+	return merge(a, b, LastWins)
+}
+
+// MergeWith merges two SortedRanges in the same way as Merge except that, where two elements
+// from a and b are equal, the resulting SortedRange contains resolve(elementFromA, elementFromB).
+func MergeWith(a SortedRange, b SortedRange, resolve Resolver) SortedRange {
+	return merge(a, b, resolve)
+}
+
+// MergeAll merges any number of SortedRanges to produce a SortedRange which represents
+// the deduplicated merge of all of them. Where elements from two or more of the ranges
+// are equal, the resulting SortedRange contains the element from the latest of those
+// ranges. Ranges that do not overlap any other range are concatenated rather than merged.
+func MergeAll(ranges ...SortedRange) SortedRange {
+	return mergeAll(ranges, LastWins)
+}
+
+// MergeAllWith merges any number of SortedRanges in the same way as MergeAll except that equal
+// elements are combined with the specified Resolver, in the order in which their ranges
+// were specified.
+func MergeAllWith(resolve Resolver, ranges ...SortedRange) SortedRange {
+	return mergeAll(ranges, resolve)
 }
 
 // EmptyRange is a SortedRange that has no elements.
@@ -149,3 +287,60 @@ type UnsortedRange interface {
 func NewUnsortedRange() UnsortedRange {
 	return &mutableRange{}
 }
+
+// NewUnsortedRangeWith returns an UnsortedRange whose equal elements are combined with the
+// specified Resolver, in the order in which they were added, when the range is frozen.
+func NewUnsortedRangeWith(resolve Resolver) UnsortedRange {
+	return newMutableRange(resolve)
+}
+
+// A Log is a timeseries log that never stops accepting writes. Writers extend
+// the log with Append and are never blocked by readers. Readers call Snapshot
+// to obtain a sorted, deduplicated view of everything appended to the log prior to
+// the call.
+type Log interface {
+	// Append adds the specified elements to the log.
+	Append(elements []Element) error
+	// Snapshot answers a SortedRange containing every element appended
+	// to the log before Snapshot was called. Tombstones, and the elements
+	// they delete, are hidden from the snapshot.
+	Snapshot() SortedRange
+	// DeleteRange removes the elements in [from, to) that were appended to the log
+	// before DeleteRange was called. Elements that have already been archived are not
+	// affected; to delete those, append Tombstones instead. DeleteRange waits for any
+	// archive that is in progress to complete.
+	DeleteRange(from Element, to Element)
+	// Archive hands the archiver the part of the log that sorts before the
+	// specified element, including any Tombstones, and, once the archiver has
+	// durably written it, truncates that part from memory. Elements older than
+	// the specified element that are appended while the archiver is running are
+	// retained by the log.
+	Archive(before Element, archiver Archiver) error
+	// SetCompactionPolicy sets the policy that is used to compact the history of the log.
+	// When a snapshot finds that the shape of the history exceeds the policy, the history is
+	// compacted in the background. Compaction is disabled by the zero CompactionPolicy,
+	// which is the default.
+	SetCompactionPolicy(policy CompactionPolicy)
+	// Close stops the log from starting background compactions and waits for any that
+	// are running to complete. The log may still be appended to and read after it is closed.
+	Close()
+}
+
+// An Archiver copies sections of a Log to persistent storage.
+type Archiver interface {
+	// Archive writes the specified range to persistent storage, returning
+	// only once the range has been durably written. If an error is
+	// returned, the log retains the range in memory.
+	Archive(r SortedRange) error
+}
+
+// NewLog returns an empty Log.
+func NewLog() Log {
+	return newGenerationalLog(LastWins)
+}
+
+// NewLogWith returns an empty Log whose equal elements are combined with the specified
+// Resolver, in the order in which they were appended.
+func NewLogWith(resolve Resolver) Log {
+	return newGenerationalLog(resolve)
+}
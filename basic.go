@@ -2,6 +2,7 @@ package tsl
 
 import (
 	"fmt"
+	"sort"
 )
 
 // basicRange is a type that encapsulates the essential element of all Range
@@ -66,3 +67,52 @@ func (c *basicCursor) Fill(buffer []Element) int {
 	c.next += max
 	return max
 }
+
+// Seek uses a binary search to advance the cursor to the first element not less than e.
+func (c *basicCursor) Seek(e Element) {
+	c.next = seek(c.elements, c.next, len(c.elements), e)
+}
+
+// get uses a binary search to find the element of a sorted slice that is equal to key.
+func get(elements []Element, key Element) (Element, bool) {
+	i := seek(elements, 0, len(elements), key)
+	if i < len(elements) && !key.Less(elements[i]) {
+		return elements[i], true
+	}
+	return nil, false
+}
+
+// seek answers the index of the first element of elements[lo:hi] that is not less than e,
+// or hi if there is no such element.
+func seek(elements []Element, lo int, hi int, e Element) int {
+	return lo + sort.Search(hi-lo, func(i int) bool {
+		return !elements[lo+i].Less(e)
+	})
+}
+
+// reverseCursor iterates backwards over an immutable, sorted, deduplicated slice of elements.
+type reverseCursor struct {
+	next     int // the number of elements that have not been answered
+	elements []Element
+}
+
+func (c *reverseCursor) Next() Element {
+	if c.next > 0 {
+		c.next--
+		return c.elements[c.next]
+	} else {
+		return nil
+	}
+}
+
+func (c *reverseCursor) Fill(buffer []Element) int {
+	max := len(buffer)
+	if max > c.next {
+		max = c.next
+	}
+	for i := 0; i < max; i++ {
+		c.next--
+		buffer[i] = c.elements[c.next]
+	}
+	return max
+}
@@ -0,0 +1,42 @@
+package tsl
+
+// Inclusivity specifies which of the bounds of a range of elements are included in the range.
+type Inclusivity int
+
+const (
+	// IncludeFrom includes elements equal to the lower bound.
+	IncludeFrom Inclusivity = 1 << iota
+	// IncludeTo includes elements equal to the upper bound.
+	IncludeTo
+)
+
+const (
+	// Exclusive excludes both bounds: (from, to)
+	Exclusive Inclusivity = 0
+	// HalfOpen includes the lower bound only: [from, to)
+	HalfOpen = IncludeFrom
+	// Inclusive includes both bounds: [from, to]
+	Inclusive = IncludeFrom | IncludeTo
+)
+
+// Between answers the sub-range of r whose elements lie between from and to, including
+// or excluding each bound as specified by inclusivity. The sub-range is found with two
+// calls to Partition, and so shares storage with r. If to sorts before from, the answered
+// range is empty.
+func Between(r SortedRange, from Element, to Element, inclusivity Inclusivity) SortedRange {
+	if to.Less(from) {
+		return EmptyRange
+	}
+
+	lower, upper := LessOrEqualOrder, LessOrder
+	if inclusivity&IncludeFrom != 0 {
+		lower = LessOrder
+	}
+	if inclusivity&IncludeTo != 0 {
+		upper = LessOrEqualOrder
+	}
+
+	_, rest := r.Partition(from, lower)
+	between, _ := rest.Partition(to, upper)
+	return useEmptyRangeIfEmpty(between)
+}
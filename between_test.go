@@ -0,0 +1,81 @@
+package tsl
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// checkBetween checks that Between answers the elements of the range that lie between
+// each pair of bounds and that the answered ranges satisfy the SortedRange invariants.
+// A new range is built for each call to Between so that ranges which are not yet merged
+// are tested before they are merged.
+func checkBetween(t *testing.T, build func() SortedRange) {
+	all := AsSlice(build())
+	bounds := [][2]int{{-5, -1}, {-1, 200}, {0, 0}, {0, 1}, {3, 17}, {4, 16}, {40, 60}, {98, 99}, {99, 150}, {20, 10}}
+	for _, b := range bounds {
+		from, to := intElement{b[0]}, intElement{b[1]}
+		for _, inclusivity := range []Inclusivity{Exclusive, IncludeFrom, IncludeTo, Inclusive} {
+			expected := Elements{}
+			for _, e := range all {
+				v := e.(intElement).value
+				if (v > b[0] || (v == b[0] && inclusivity&IncludeFrom != 0)) &&
+					(v < b[1] || (v == b[1] && inclusivity&IncludeTo != 0)) {
+					expected = append(expected, e)
+				}
+			}
+
+			r := build()
+			got := Between(r, from, to, inclusivity)
+			context := fmt.Sprintf("Between(%v, %v, %d) over %v", from, to, inclusivity, r)
+			if !reflect.DeepEqual(Elements(AsSlice(got)), expected) {
+				t.Fatalf("%s. got: %v, expected: %v", context, AsSlice(got), expected)
+			}
+			if err := checkSortedRangeInvariants(got); err != nil {
+				t.Fatalf("%s. got: %v. %v", context, got, err)
+			}
+		}
+	}
+}
+
+func Test_Between_Immutable(t *testing.T) {
+	checkBetween(t, func() SortedRange {
+		return newImmutableRange(NewElements(sequence(0, 100, 2)))
+	})
+	checkBetween(t, func() SortedRange {
+		return EmptyRange
+	})
+}
+
+func Test_Between_Disjoint(t *testing.T) {
+	checkBetween(t, func() SortedRange {
+		return MergeAll(
+			newImmutableRange(NewElements(sequence(60, 100, 3))),
+			newImmutableRange(NewElements(sequence(0, 20, 3))),
+			newImmutableRange(NewElements(sequence(30, 50, 3))),
+		)
+	})
+}
+
+func Test_Between_Unmerged(t *testing.T) {
+	checkBetween(t, func() SortedRange {
+		u := NewUnsortedRange()
+		u.Add(NewElements(sequence(0, 100, 2)))
+		u.Add(NewElements(sequence(1, 100, 2)))
+		return u.Freeze()
+	})
+	checkBetween(t, func() SortedRange {
+		return MergeAll(
+			newImmutableRange(NewElements(sequence(0, 100, 3))),
+			newImmutableRange(NewElements(sequence(1, 100, 3))),
+			newImmutableRange(NewElements(sequence(0, 100, 2))),
+		)
+	})
+}
+
+func Test_Between_Segment(t *testing.T) {
+	s := writeSegment(t, newImmutableRange(NewElements(sequence(0, 100, 1))))
+	checkBetween(t, func() SortedRange {
+		return s
+	})
+}
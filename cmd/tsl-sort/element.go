@@ -0,0 +1,103 @@
+package main
+
+import (
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/wildducktheories/timeserieslog"
+)
+
+// element represents a line of input and the key extracted from it. When times are
+// compared, the key is parsed into a time which is compared before the key. Lines with
+// identical keys are distinguished by their position in the input stream, so the sort
+// is stable.
+type element struct {
+	at      time.Time // the time parsed from the key, if times are compared
+	key     string
+	line    string
+	ordinal int
+}
+
+func (e *element) Less(o tsl.Element) bool {
+	return e.compare(o.(*element)) < 0
+}
+
+// compare answers a negative number, zero or a positive number as e sorts before, with
+// or after o.
+func (e *element) compare(o *element) int {
+	if e.at != o.at {
+		// identical times, such as the zero times of elements whose keys are not
+		// parsed, are not compared
+		if c := e.at.Compare(o.at); c != 0 {
+			return c
+		}
+	}
+	if c := strings.Compare(e.key, o.key); c != 0 {
+		return c
+	}
+	return cmp.Compare(e.ordinal, o.ordinal)
+}
+
+// lineCodec encodes an element as the uvarint of its ordinal, the uvarint of the
+// length of its key plus one, its key and its line. A key length of zero means that
+// the key is the whole line, in which case the key is not encoded. If times are compared,
+// the element is prefixed by the varint of the seconds and the uvarint of the nanoseconds
+// of its time since the Unix epoch.
+type lineCodec struct {
+	timed bool
+}
+
+var errBadElement = errors.New("malformed element.")
+
+func (c lineCodec) Encode(e tsl.Element) ([]byte, error) {
+	le := e.(*element)
+	var data []byte
+	if c.timed {
+		data = binary.AppendVarint(data, le.at.Unix())
+		data = binary.AppendUvarint(data, uint64(le.at.Nanosecond()))
+	}
+	data = binary.AppendUvarint(data, uint64(le.ordinal))
+	if le.key == le.line {
+		data = binary.AppendUvarint(data, 0)
+	} else {
+		data = binary.AppendUvarint(data, uint64(len(le.key)+1))
+		data = append(data, le.key...)
+	}
+	return append(data, le.line...), nil
+}
+
+func (c lineCodec) Decode(data []byte) (tsl.Element, error) {
+	var at time.Time
+	if c.timed {
+		seconds, n := binary.Varint(data)
+		if n <= 0 {
+			return nil, errBadElement
+		}
+		data = data[n:]
+		nanos, n := binary.Uvarint(data)
+		if n <= 0 || nanos >= uint64(time.Second) {
+			return nil, errBadElement
+		}
+		data = data[n:]
+		at = time.Unix(seconds, int64(nanos)).UTC()
+	}
+
+	ordinal, n := binary.Uvarint(data)
+	if n <= 0 {
+		return nil, errBadElement
+	}
+	data = data[n:]
+	size, n := binary.Uvarint(data)
+	if n <= 0 || size > uint64(len(data)-n)+1 {
+		return nil, errBadElement
+	}
+	data = data[n:]
+	if size == 0 {
+		line := string(data)
+		return &element{at: at, key: line, line: line, ordinal: int(ordinal)}, nil
+	}
+	return &element{at: at, key: string(data[0 : size-1]), line: string(data[size-1:]), ordinal: int(ordinal)}, nil
+}
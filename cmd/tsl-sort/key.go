@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// keyFunc extracts the sort key from a line of input. A line that does not contain
+// a key answers the empty string, which sorts before every other key.
+type keyFunc func(line string) string
+
+// parseKeySpec parses a sort(1) style key specification of the form F1[,F2], where
+// F1 and F2 are 1-based field numbers. The key extends from the start of field F1
+// to the end of field F2 or, if F2 is omitted, to the end of the line.
+func parseKeySpec(spec string) (from int, to int, err error) {
+	first, last, ranged := strings.Cut(spec, ",")
+	if from, err = strconv.Atoi(first); err != nil || from < 1 {
+		return 0, 0, fmt.Errorf("invalid key specification: %q", spec)
+	}
+	if !ranged {
+		return from, 0, nil
+	}
+	if to, err = strconv.Atoi(last); err != nil || to < from {
+		return 0, 0, fmt.Errorf("invalid key specification: %q", spec)
+	}
+	return from, to, nil
+}
+
+// isBlank answers true if b is a blank, which, as in the C locale of sort(1), is a space
+// or a tab. Other whitespace, including multi-byte spaces such as U+00A0, is part of a field.
+func isBlank(b byte) bool {
+	return b == ' ' || b == '\t'
+}
+
+// fieldKey answers a keyFunc that extracts fields from through to of each line, where
+// a to of zero means the end of the line. Fields are separated by separator or, if separator
+// is empty, by the empty string between a non-blank and a blank, so, as in sort(1), each
+// field but the first includes the blanks that precede it. If skipBlanks is true, as with
+// the -b option of sort(1), the blanks at the start of the key are skipped. The key is a
+// substring of the line, including the separators between the extracted fields.
+func fieldKey(separator string, from int, to int, skipBlanks bool) keyFunc {
+	// end answers the index of the end of the field that starts at i
+	end := func(line string, i int) int {
+		if separator == "" {
+			for i < len(line) && isBlank(line[i]) {
+				i++
+			}
+			for i < len(line) && !isBlank(line[i]) {
+				i++
+			}
+			return i
+		} else if j := strings.Index(line[i:], separator); j >= 0 {
+			return i + j
+		}
+		return len(line)
+	}
+
+	return func(line string) string {
+		// find the starts of fields from and to, if the line has them
+		start, last := -1, -1
+		for i, field := 0, 1; ; field++ {
+			if field == from {
+				start = i
+			}
+			if field == to {
+				last = i
+			}
+			if field >= from && field >= to {
+				break
+			}
+			j := end(line, i)
+			if separator == "" {
+				// the fields that follow the last are empty
+				i = j
+			} else if j < len(line) {
+				i = j + len(separator)
+			} else {
+				break
+			}
+		}
+		if start < 0 {
+			return ""
+		}
+
+		stop := len(line)
+		if last >= 0 {
+			stop = end(line, last)
+		}
+		if skipBlanks {
+			for start < stop && isBlank(line[start]) {
+				start++
+			}
+		}
+		return line[start:stop]
+	}
+}
+
+// regexKey answers a keyFunc that extracts the first capture group of re or, if re
+// has no capture groups, the leftmost match of re.
+func regexKey(re *regexp.Regexp) keyFunc {
+	group := 0
+	if re.NumSubexp() > 0 {
+		group = 1
+	}
+	return func(line string) string {
+		m := re.FindStringSubmatchIndex(line)
+		if m == nil || m[2*group] < 0 {
+			return ""
+		}
+		return line[m[2*group]:m[2*group+1]]
+	}
+}
+
+// jsonKey answers a keyFunc that extracts the value at a dot separated path within
+// a line that contains a JSON object. Strings answer their unquoted value and numbers
+// and booleans answer their JSON text. Lines that are not JSON objects, or do not
+// contain a scalar value at the path, have no key.
+func jsonKey(path string) keyFunc {
+	names := strings.Split(path, ".")
+	return func(line string) string {
+		decoder := json.NewDecoder(strings.NewReader(line))
+		decoder.UseNumber()
+		var value interface{}
+		if decoder.Decode(&value) != nil {
+			return ""
+		}
+		for _, name := range names {
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return ""
+			}
+			value = object[name]
+		}
+		switch v := value.(type) {
+		case string:
+			return v
+		case json.Number:
+			return string(v)
+		case bool:
+			return strconv.FormatBool(v)
+		default:
+			return ""
+		}
+	}
+}
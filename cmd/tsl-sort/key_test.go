@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// checkKeys checks that key answers the expected key for each line.
+func checkKeys(t *testing.T, key keyFunc, lines []string, expected []string) {
+	got := []string{}
+	for _, line := range lines {
+		got = append(got, key(line))
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("keys of %q. got: %q, expected: %q", lines, got, expected)
+	}
+}
+
+func Test_Key_ParseKeySpec(t *testing.T) {
+	for spec, expected := range map[string][2]int{"1": {1, 0}, "4,4": {4, 4}, "2,5": {2, 5}} {
+		from, to, err := parseKeySpec(spec)
+		if err != nil || from != expected[0] || to != expected[1] {
+			t.Fatalf("parseKeySpec(%q). got: %d, %d, %v, expected: %v", spec, from, to, err, expected)
+		}
+	}
+	for _, spec := range []string{"", "0", "x", "3,2", "1,", "1.2"} {
+		if _, _, err := parseKeySpec(spec); err == nil {
+			t.Fatalf("parseKeySpec(%q) should fail", spec)
+		}
+	}
+}
+
+func Test_Key_Fields_Whitespace(t *testing.T) {
+	// as in sort(1), each field but the first includes its leading blanks
+	lines := []string{"a b c d", "  a  b\tc  ", "a", ""}
+	checkKeys(t, fieldKey("", 2, 2, false), lines, []string{" b", "  b", "", ""})
+	checkKeys(t, fieldKey("", 2, 3, false), lines, []string{" b c", "  b\tc", "", ""})
+	checkKeys(t, fieldKey("", 3, 0, false), lines, []string{" c d", "\tc  ", "", ""})
+	checkKeys(t, fieldKey("", 1, 0, false), lines, []string{"a b c d", "  a  b\tc  ", "a", ""})
+	checkKeys(t, fieldKey("", 1, 1, false), lines, []string{"a", "  a", "a", ""})
+	checkKeys(t, fieldKey("", 4, 0, false), []string{"a b c  ", "a b c"}, []string{"  ", ""})
+
+	// as with sort -b, the leading blanks of the key are skipped
+	checkKeys(t, fieldKey("", 2, 2, true), lines, []string{"b", "b", "", ""})
+	checkKeys(t, fieldKey("", 2, 3, true), lines, []string{"b c", "b\tc", "", ""})
+	checkKeys(t, fieldKey("", 3, 0, true), lines, []string{"c d", "c  ", "", ""})
+	checkKeys(t, fieldKey("", 1, 0, true), lines, []string{"a b c d", "a  b\tc  ", "a", ""})
+
+	// only spaces and tabs are blanks, so multi-byte spaces are part of a field
+	lines = []string{"\u00a0a b", "a\u00a0b c", "a\u3000b\u00a0 c"}
+	checkKeys(t, fieldKey("", 1, 1, false), lines, []string{"\u00a0a", "a\u00a0b", "a\u3000b\u00a0"})
+	checkKeys(t, fieldKey("", 2, 2, true), lines, []string{"b", "c", "c"})
+}
+
+func Test_Key_Fields_Separator(t *testing.T) {
+	lines := []string{"a,b,c", ",b,", "a", "a,,c", "a, b"}
+	checkKeys(t, fieldKey(",", 2, 2, false), lines, []string{"b", "b", "", "", " b"})
+	checkKeys(t, fieldKey(",", 3, 3, false), lines, []string{"c", "", "", "c", ""})
+	checkKeys(t, fieldKey(",", 2, 0, false), lines, []string{"b,c", "b,", "", ",c", " b"})
+	checkKeys(t, fieldKey(",", 2, 2, true), lines, []string{"b", "b", "", "", "b"})
+	checkKeys(t, fieldKey("::", 2, 2, false), []string{"a::b::c"}, []string{"b"})
+}
+
+// Test_Key_Fields_SortCompatible checks the example of a blank separated key that differs
+// from the key of sort -k 2,2 if leading blanks are skipped.
+func Test_Key_Fields_SortCompatible(t *testing.T) {
+	input := "x  b 1\ny a 2\nz   c 3\nw a 0\n"
+	for _, c := range []struct {
+		skipBlanks bool
+		expected   string
+	}{
+		// LC_ALL=C sort -s -k 2,2
+		{false, "z   c 3\nx  b 1\ny a 2\nw a 0\n"},
+		// LC_ALL=C sort -s -b -k 2,2
+		{true, "y a 2\nw a 0\nx  b 1\nz   c 3\n"},
+	} {
+		s := &sorter{budget: 1 << 20, tmpdir: t.TempDir(), key: fieldKey("", 2, 2, c.skipBlanks)}
+		out := &bytes.Buffer{}
+		if err := s.sort(strings.NewReader(input), out); err != nil || out.String() != c.expected {
+			t.Fatalf("sort with skipBlanks %v. got: %q, %v, expected: %q", c.skipBlanks, out.String(), err, c.expected)
+		}
+	}
+}
+
+func Test_Key_Regex(t *testing.T) {
+	lines := []string{"at [12:00] ok", "no time", "[9:30]"}
+	checkKeys(t, regexKey(regexp.MustCompile(`\[([0-9:]+)\]`)), lines, []string{"12:00", "", "9:30"})
+	checkKeys(t, regexKey(regexp.MustCompile(`[0-9]+`)), lines, []string{"12", "", "9"})
+	checkKeys(t, regexKey(regexp.MustCompile(`(x)?time`)), lines, []string{"", "", ""})
+}
+
+func Test_Key_JSON(t *testing.T) {
+	lines := []string{
+		`{"request":{"time":"2016-03-06T22:26:43Z"},"n":12}`,
+		`{"request":{"time":1457303203},"n":true}`,
+		`{"request":"x"}`,
+		`not json`,
+	}
+	checkKeys(t, jsonKey("request.time"), lines, []string{"2016-03-06T22:26:43Z", "1457303203", "", ""})
+	checkKeys(t, jsonKey("n"), lines, []string{"12", "true", "", ""})
+	checkKeys(t, jsonKey("request"), lines, []string{"", "", "x", ""})
+}
+
+func Test_Key_NewKeyFunc(t *testing.T) {
+	if key, err := newKeyFunc("", "", false, "", ""); key != nil || err != nil {
+		t.Fatalf("no key options. got: %v, expected: nil", err)
+	}
+	for _, args := range [][4]string{{"1", "", "x", ""}, {"", ",", "", ""}, {"", "", "(", ""}, {"1", "", "", "a"}} {
+		if _, err := newKeyFunc(args[0], args[1], false, args[2], args[3]); err == nil {
+			t.Fatalf("newKeyFunc(%q) should fail", args)
+		}
+	}
+	if _, err := newKeyFunc("", "", true, "", ""); err == nil {
+		t.Fatalf("-b without -k should fail")
+	}
+}
+
+func Test_Sort_Keyed(t *testing.T) {
+	input := []string{
+		`10.0.0.1 - - [06/Mar/2016:22:26:45] "GET /c"`,
+		`10.0.0.2 - - [06/Mar/2016:22:26:43] "GET /a"`,
+		`10.0.0.1 - - [06/Mar/2016:22:26:44] "GET /b"`,
+		`10.0.0.3 - - [06/Mar/2016:22:26:43] "GET /d"`,
+	}
+	expected := []string{input[1], input[3], input[2], input[0]}
+
+	for _, key := range []keyFunc{fieldKey("", 4, 4, false), regexKey(regexp.MustCompile(`\[(.*)\]`))} {
+		for _, budget := range []int{1 << 20, elementOverhead} {
+			s := &sorter{budget: budget, tmpdir: t.TempDir(), key: key}
+			out := &bytes.Buffer{}
+			if err := s.sort(strings.NewReader(strings.Join(input, "\n")), out); err != nil {
+				t.Fatalf("sort failed. got: %v, expected: nil", err)
+			}
+			got := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+			if !reflect.DeepEqual(got, expected) {
+				t.Fatalf("keyed sort with budget %d. got: %q, expected: %q", budget, got, expected)
+			}
+		}
+	}
+}
+
+func Test_LineCodec(t *testing.T) {
+	for _, e := range []*element{
+		{key: "line", line: "line", ordinal: 1},
+		{key: "k", line: "a k line", ordinal: 300},
+		{key: "", line: "no key", ordinal: 2},
+		{key: "", line: "", ordinal: 3},
+	} {
+		data, _ := lineCodec{}.Encode(e)
+		got, err := lineCodec{}.Decode(data)
+		if err != nil || !reflect.DeepEqual(got, e) {
+			t.Fatalf("codec round trip. got: %v, %v, expected: %v", got, err, e)
+		}
+	}
+	if _, err := (lineCodec{}).Decode([]byte{1, 10, 'x'}); err != errBadElement {
+		t.Fatalf("truncated key. got: %v, expected: %v", err, errBadElement)
+	}
+}
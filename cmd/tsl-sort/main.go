@@ -0,0 +1,157 @@
+// tsl-sort sorts the lines read from stdin and writes them to stdout. It is optimized for
+// timeseries data, which is almost, but not completely, sorted. Input that does not fit
+// within the memory budget is spilled to temporary segment files, which are merged with
+// the remainder of the input once it has been read. Alternatively, if the lateness of the
+// input is bounded in time, lines are written progressively as a watermark passes them.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"runtime/debug"
+	"time"
+)
+
+func main() {
+	s := &sorter{}
+
+	budget := 0
+	dumpStatistics := false
+	comment := ""
+	keySpec := ""
+	separator := ""
+	skipBlanks := false
+	keyRegex := ""
+	keyPath := ""
+	timeFormat := ""
+	unparseable := ""
+	late := ""
+	lateOutput := ""
+
+	flag.IntVar(&budget, "memory", 256, "The approximate number of MiB of input to buffer in memory before spilling to disk.")
+	flag.StringVar(&s.tmpdir, "tmpdir", os.TempDir(), "The directory in which to create temporary spill files.")
+	flag.BoolVar(&dumpStatistics, "statistics", false, "Dump a statistics record to stderr on exit.")
+	flag.StringVar(&comment, "comment", "", "Arbitrary text to be logged as an argument.")
+	flag.StringVar(&keySpec, "k", "", "Sort by a key of the form F1[,F2] that spans fields F1 to F2 (or the end of the line) of each line.")
+	flag.StringVar(&separator, "t", "", "The field separator used by -k. By default, as in sort(1), fields are separated by the empty string between a non-blank and a blank (a space or tab), so each field but the first includes its leading blanks.")
+	flag.BoolVar(&skipBlanks, "b", false, "Ignore the leading blanks of the key selected by -k.")
+	flag.StringVar(&keyRegex, "key-regex", "", "Sort by the first capture group (or the match, if there are no groups) of a regular expression.")
+	flag.StringVar(&keyPath, "json-key", "", "Sort by the value at a dot separated path of a JSON object on each line.")
+	flag.StringVar(&timeFormat, "time-format", "", "Compare keys as times in one of the formats RFC3339, RFC3339Nano, unix, unixms, unixns or auto, or in a strftime or Go time layout.")
+	flag.StringVar(&unparseable, "unparseable", "fail", "What to do with lines whose key is not a time: fail, skip or end.")
+	flag.DurationVar(&s.lateness, "lateness", 0, "Write lines progressively as soon as they are older than the latest time read less this allowed lateness, e.g. 30s. Requires --time-format.")
+	flag.StringVar(&late, "late", "merge", "What to do with lines that are later than --lateness: merge (write out of order and warn), drop or side (write to --late-output).")
+	flag.StringVar(&lateOutput, "late-output", "", "The file to which --late=side writes late lines.")
+	flag.Parse()
+
+	var err error
+	if s.key, err = newKeyFunc(keySpec, separator, skipBlanks, keyRegex, keyPath); err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: %v\n", err)
+		os.Exit(2)
+	}
+	if timeFormat != "" {
+		if s.time, err = newTimeFunc(timeFormat); err != nil {
+			fmt.Fprintf(os.Stderr, "fatal: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	if s.policy, err = parsePolicy(unparseable); err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: %v\n", err)
+		os.Exit(2)
+	}
+
+	if s.lateAction, err = parseLateAction(late); err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: %v\n", err)
+		os.Exit(2)
+	}
+	if s.lateness < 0 || s.lateness > 0 && s.time == nil {
+		fmt.Fprintf(os.Stderr, "fatal: --lateness must be positive and requires --time-format\n")
+		os.Exit(2)
+	}
+	if (s.lateAction == sideLate) != (lateOutput != "") {
+		fmt.Fprintf(os.Stderr, "fatal: --late-output is required by, and only valid with, --late=side\n")
+		os.Exit(2)
+	}
+	if lateOutput != "" {
+		f, err := os.Create(lateOutput)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "fatal: %v\n", err)
+			os.Exit(2)
+		}
+		s.lateOutput = f
+	}
+	s.warnings = os.Stderr
+
+	if budget <= 0 {
+		fmt.Fprintf(os.Stderr, "fatal: --memory must be positive\n")
+		os.Exit(2)
+	}
+	s.budget = budget << 20
+
+	started := time.Now()
+	if s.lateness > 0 {
+		err = s.watermarked(os.Stdin, os.Stdout)
+	} else {
+		// the budget bounds the live heap of a sort, so garbage is only collected as the heap
+		// approaches twice the budget, rather than each time the heap doubles while the buffer
+		// is filled, which otherwise costs more than the sort itself.
+		debug.SetGCPercent(-1)
+		debug.SetMemoryLimit(2*int64(s.budget) + 64<<20)
+		err = s.sort(os.Stdin, os.Stdout)
+	}
+	if f, ok := s.lateOutput.(*os.File); ok {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+
+	s.stats.Duration = int64(time.Since(started))
+	s.stats.DurationSeconds = float64(s.stats.Duration) / float64(time.Second)
+	s.stats.Args = os.Args[1:]
+	if dumpStatistics {
+		json.NewEncoder(os.Stderr).Encode(s.stats)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// newKeyFunc answers the keyFunc selected by at most one of the key options, or nil
+// if none of them were specified.
+func newKeyFunc(keySpec string, separator string, skipBlanks bool, keyRegex string, keyPath string) (keyFunc, error) {
+	selected := 0
+	for _, option := range []string{keySpec, keyRegex, keyPath} {
+		if option != "" {
+			selected++
+		}
+	}
+	switch {
+	case selected > 1:
+		return nil, fmt.Errorf("at most one of -k, --key-regex and --json-key may be specified")
+	case separator != "" && keySpec == "":
+		return nil, fmt.Errorf("-t requires -k")
+	case skipBlanks && keySpec == "":
+		return nil, fmt.Errorf("-b requires -k")
+	case keySpec != "":
+		from, to, err := parseKeySpec(keySpec)
+		if err != nil {
+			return nil, err
+		}
+		return fieldKey(separator, from, to, skipBlanks), nil
+	case keyRegex != "":
+		re, err := regexp.Compile(keyRegex)
+		if err != nil {
+			return nil, err
+		}
+		return regexKey(re), nil
+	case keyPath != "":
+		return jsonKey(keyPath), nil
+	default:
+		return nil, nil
+	}
+}
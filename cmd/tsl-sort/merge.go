@@ -0,0 +1,250 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+
+	"github.com/wildducktheories/timeserieslog"
+)
+
+// runBufferSize is the number of elements that a streaming merge reads from a run at once.
+const runBufferSize = 256
+
+// run is a peekable cursor over one of the sorted runs of a streaming merge.
+type run struct {
+	cursor tsl.CursorContext
+	buffer []tsl.Element // the elements read from the cursor
+	i      int           // the index of next within buffer
+	next   tsl.Element   // the next element of the run, or nil once the run is exhausted
+}
+
+// advance reads the next element of the run, refilling the buffer from the cursor once it
+// has been consumed.
+func (r *run) advance() error {
+	if r.i++; r.i < len(r.buffer) {
+		r.next = r.buffer[r.i]
+		return nil
+	}
+	n, err := r.cursor.Fill(r.buffer[0:cap(r.buffer)])
+	r.buffer, r.i, r.next = r.buffer[0:n], 0, nil
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		r.next = r.buffer[0]
+	}
+	return nil
+}
+
+// runHeap is a heap of runs ordered by their next element.
+type runHeap []*run
+
+func (h runHeap) Len() int {
+	return len(h)
+}
+
+func (h runHeap) Less(i, j int) bool {
+	return h[i].next.Less(h[j].next)
+}
+
+func (h runHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *runHeap) Push(x interface{}) {
+	*h = append(*h, x.(*run))
+}
+
+func (h *runHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[0 : len(old)-1]
+	return x
+}
+
+// merge is a streaming merge of sorted runs. Unlike the merges of the tsl package, which
+// retain the merged elements so that they can be read by other cursors, a streaming merge
+// retains nothing but a small buffer of the next elements of each run, so runs that have
+// been spilled to disk are never read back into memory as a whole. The elements of the runs
+// must be distinct, which is guaranteed by the ordinal of each element.
+//
+// The run at the top of the heap is copied without further heap operations for as long as its
+// next element sorts before the next element of the runner-up, so runs that barely overlap,
+// as the runs of nearly sorted input do, cost one comparison per element rather than the
+// comparisons of a heap fix.
+type merge struct {
+	heap  runHeap
+	top   *run        // the run being copied, or nil if the top of the heap is to be found
+	bound tsl.Element // the next element of the runner-up, if any
+}
+
+// newMerge answers a streaming merge of the runs.
+func newMerge(runs []tsl.SortedRange) (*merge, error) {
+	m := &merge{heap: make(runHeap, 0, len(runs))}
+	for _, r := range runs {
+		run := &run{
+			cursor: tsl.OpenContext(context.Background(), r),
+			buffer: make([]tsl.Element, 0, runBufferSize),
+		}
+		if err := run.advance(); err != nil {
+			run.cursor.Close()
+			m.close()
+			return nil, err
+		} else if run.next == nil {
+			run.cursor.Close()
+			continue
+		}
+		m.heap = append(m.heap, run)
+	}
+	heap.Init(&m.heap)
+	return m, nil
+}
+
+// next answers the next element of the merge, or nil once the runs are exhausted.
+func (m *merge) next() (tsl.Element, error) {
+	if m.top == nil {
+		if len(m.heap) == 0 {
+			return nil, nil
+		}
+		m.top, m.bound = m.heap[0], nil
+		if len(m.heap) > 1 {
+			m.bound = m.heap[1].next
+			if len(m.heap) > 2 && m.heap[2].next.Less(m.bound) {
+				m.bound = m.heap[2].next
+			}
+		}
+	}
+
+	e := m.top.next
+	if err := m.top.advance(); err != nil {
+		return nil, err
+	}
+	if m.top.next == nil {
+		m.top.cursor.Close()
+		heap.Pop(&m.heap)
+		m.top = nil
+	} else if m.bound != nil && !m.top.next.Less(m.bound) {
+		heap.Fix(&m.heap, 0)
+		m.top = nil
+	}
+	return e, nil
+}
+
+// close closes the cursors of the runs that have not been exhausted.
+func (m *merge) close() {
+	for _, r := range m.heap {
+		r.cursor.Close()
+	}
+	m.heap = nil
+}
+
+// streamMerge calls write for each element of the runs in sorted order. The merge stops at
+// the first error answered by write.
+func streamMerge(runs []tsl.SortedRange, write func(e *element) error) error {
+	m, err := newMerge(runs)
+	if err != nil {
+		return err
+	}
+	defer m.close()
+
+	for {
+		e, err := m.next()
+		if err != nil {
+			return err
+		} else if e == nil {
+			return nil
+		}
+		if err := write(e.(*element)); err != nil {
+			return err
+		}
+	}
+}
+
+// mergedRuns is a SortedRange of the elements of sorted runs, whose cursors merge the runs
+// as they are read, so that runs which have been spilled to disk can be merged into a new
+// segment without being read back into memory.
+type mergedRuns []tsl.SortedRange
+
+func (r mergedRuns) Limit() int {
+	limit := 0
+	for _, run := range r {
+		limit += run.Limit()
+	}
+	return limit
+}
+
+func (r mergedRuns) First() tsl.Element {
+	var first tsl.Element
+	for _, run := range r {
+		if e := run.First(); e != nil && (first == nil || e.Less(first)) {
+			first = e
+		}
+	}
+	return first
+}
+
+func (r mergedRuns) Last() tsl.Element {
+	var last tsl.Element
+	for _, run := range r {
+		if e := run.Last(); e != nil && (last == nil || last.Less(e)) {
+			last = e
+		}
+	}
+	return last
+}
+
+func (r mergedRuns) Open() tsl.Cursor {
+	m, err := newMerge(r)
+	return &mergedCursor{merge: m, err: err}
+}
+
+// Partition partitions each of the runs.
+func (r mergedRuns) Partition(e tsl.Element, o tsl.Order) (tsl.SortedRange, tsl.SortedRange) {
+	before, after := make(mergedRuns, len(r)), make(mergedRuns, len(r))
+	for i, run := range r {
+		before[i], after[i] = run.Partition(e, o)
+	}
+	return before, after
+}
+
+// mergedCursor is a cursor over mergedRuns. Like the cursors of segments, it stops at the
+// first error encountered while reading the runs, which is answered by Err.
+type mergedCursor struct {
+	merge *merge // the merge, or nil once it has been closed
+	err   error
+}
+
+func (c *mergedCursor) Next() tsl.Element {
+	if c.merge == nil {
+		return nil
+	}
+	e, err := c.merge.next()
+	if err != nil || e == nil {
+		c.err = err
+		c.Close()
+	}
+	return e
+}
+
+func (c *mergedCursor) Fill(buffer []tsl.Element) int {
+	for i := range buffer {
+		if buffer[i] = c.Next(); buffer[i] == nil {
+			return i
+		}
+	}
+	return len(buffer)
+}
+
+// Err answers the first error encountered while reading the runs, if any.
+func (c *mergedCursor) Err() error {
+	return c.err
+}
+
+// Close closes the cursors of the runs.
+func (c *mergedCursor) Close() error {
+	if c.merge != nil {
+		c.merge.close()
+		c.merge = nil
+	}
+	return nil
+}
@@ -0,0 +1,442 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wildducktheories/timeserieslog"
+)
+
+const (
+	// elementOverhead is the approximate number of bytes of memory used by each buffered
+	// element in addition to the bytes of its line.
+	elementOverhead = 64
+	// batchSize is the number of elements that are sorted and added to the buffer at once,
+	// and allocated at once.
+	batchSize = 1024
+	// readBlockSize is the number of bytes of input that are read at once.
+	readBlockSize = 1 << 16
+	// fanIn is the number of spilled runs at which some of them are merged into a new run,
+	// so that the number of open spill files is bounded.
+	fanIn = 16
+)
+
+// statistics is a JSON encodable type which contains
+// observable statistics for the sort operation.
+type statistics struct {
+	Read            int      `json:"read"`
+	Duration        int64    `json:"duration"`
+	DurationSeconds float64  `json:"durationSeconds"`
+	Args            []string `json:"args"`
+	Budget          int      `json:"budget"`
+	Spills          int      `json:"spills"`
+	SpilledBytes    int64    `json:"spilledBytes"`
+	Merges          int      `json:"merges"`
+	Unparseable     int      `json:"unparseable"`
+	Late            int      `json:"late"`
+}
+
+// sorter sorts lines of input within a memory budget. Lines are accumulated in an
+// UnsortedRange until the budget is exceeded, at which point the range is frozen and
+// spilled to a segment file in a temporary directory. Once the input is exhausted, the
+// spilled runs and the in-memory remainder are merged with a k-way merge and streamed
+// to the output.
+//
+// Since nearly sorted input yields runs that barely overlap, most of the elements of the
+// final merge are copied from one run while it sorts before the others, at the cost of a
+// single comparison per element.
+type sorter struct {
+	budget     int           // the number of bytes that may be buffered before spilling
+	tmpdir     string        // the directory in which spill files are created
+	key        keyFunc       // extracts the sort key from each line, or nil to sort by the whole line
+	time       timeFunc      // parses the key into a time, or nil to compare keys as strings
+	policy     policy        // determines what happens to lines whose key is not a time
+	lateness   time.Duration // the allowed lateness of the watermark mode, which is selected if not zero
+	lateAction lateAction    // determines what happens to lines that are later than the lateness
+	lateOutput io.Writer     // receives late lines if lateAction is sideLate
+	warnings   io.Writer     // receives warnings about late lines if lateAction is mergeLate
+	dir        string        // the temporary directory that holds this sort's spill files
+	buffer     tsl.UnsortedRange
+	used       int           // the approximate number of bytes held by the buffer
+	added      []tsl.Element // the batch most recently added to the buffer
+	slab       []element     // elements allocated for lines not yet read
+	runs       []tsl.SortedRange
+	files      []*os.File // the open spill file of each run
+	levels     []int      // the number of times that the elements of each run have been merged
+	stats      statistics
+}
+
+// batch is a batch of elements that is sorted by a worker of fill.
+type batch struct {
+	elements []*element
+	sorted   chan struct{} // closed once the elements are sorted
+}
+
+// errStopped is answered by the reader of fill once the batches it reads are no longer added.
+var errStopped = errors.New("stopped")
+
+// sort reads lines from in and writes them to out in sorted order.
+func (s *sorter) sort(in io.Reader, out io.Writer) (err error) {
+	defer func() {
+		if cerr := s.cleanup(); err == nil {
+			err = cerr
+		}
+	}()
+
+	s.stats.Budget = s.budget
+	if err := s.fill(in); err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriterSize(out, 1<<16)
+	err = streamMerge(append(s.runs, s.buffer.Freeze()), func(e *element) error {
+		return writeLine(writer, e)
+	})
+	if err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+// fill reads the lines of in into the buffer, spilling it whenever the budget is exceeded.
+// The lines are read by one goroutine, which hands each batch of elements to one of a pool of
+// workers to be sorted, while the sorted batches are added to the buffer in the order in which
+// they were read, so a sort uses as many processors as are available for all but adding
+// lines to the buffer and merging.
+func (s *sorter) fill(in io.Reader) error {
+	s.buffer = tsl.NewUnsortedRange()
+
+	workers := runtime.GOMAXPROCS(0)
+	work := make(chan *batch, workers)      // batches to be sorted
+	batches := make(chan *batch, 2*workers) // batches to be added, in the order in which they were read
+	free := make(chan []*element, 3*workers)
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for b := range work {
+				sortBatch(b.elements)
+				close(b.sorted)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(work)
+		defer close(batches)
+
+		var elements []*element
+		send := func() error {
+			b := &batch{elements: elements, sorted: make(chan struct{})}
+			elements = nil
+			for _, c := range []chan *batch{work, batches} {
+				select {
+				case c <- b:
+				case <-stop:
+					return errStopped
+				}
+			}
+			return nil
+		}
+		err := s.read(in, func(e *element) error {
+			if elements == nil {
+				select {
+				case elements = <-free:
+				default:
+					elements = make([]*element, 0, batchSize)
+				}
+			}
+			elements = append(elements, e)
+			if len(elements) == batchSize {
+				return send()
+			}
+			return nil
+		}, nil)
+		if err == nil && len(elements) > 0 {
+			err = send()
+		}
+		done <- err
+	}()
+
+	// once adding fails, the remaining batches are drained until the reader stops
+	var err error
+	for b := range batches {
+		<-b.sorted
+		if err != nil {
+			continue
+		} else if err = s.add(b.elements); err != nil {
+			close(stop)
+		}
+		select {
+		case free <- b.elements[0:0]:
+		default:
+		}
+	}
+	if rerr := <-done; err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// read reads lines from in and calls add with an element for each line that is not skipped.
+// The input is read in blocks, each of which is converted to a string once, so the lines,
+// and the keys within them, share the string of their block rather than being allocated
+// one by one. If idle is not nil, it is called before a read that might block because no
+// input is buffered, for example while the input is a pipe from a slow writer.
+func (s *sorter) read(in io.Reader, add func(e *element) error, idle func() error) error {
+	block := make([]byte, readBlockSize)
+	buffered := 0 // the length of the incomplete line at the start of block
+	for {
+		if idle != nil && buffered == 0 {
+			if err := idle(); err != nil {
+				return err
+			}
+		}
+		if buffered == len(block) {
+			block = append(block, make([]byte, len(block))...)
+		}
+		n, rerr := in.Read(block[buffered:])
+		text := string(block[0 : buffered+n])
+		for i := strings.IndexByte(text, '\n'); i >= 0; i = strings.IndexByte(text, '\n') {
+			if err := s.addLine(text[0:i], add); err != nil {
+				return err
+			}
+			text = text[i+1:]
+		}
+		buffered = copy(block, text)
+		if rerr == io.EOF {
+			if buffered > 0 {
+				return s.addLine(text, add)
+			}
+			return nil
+		} else if rerr != nil {
+			return rerr
+		}
+	}
+}
+
+// addLine calls add with an element for a line that has been read, unless it is skipped.
+func (s *sorter) addLine(line string, add func(e *element) error) error {
+	s.stats.Read++
+	e, err := s.element(line)
+	if err != nil {
+		return err
+	} else if e != nil {
+		return add(e)
+	}
+	return nil
+}
+
+// writeLine writes the line of an element to a writer.
+func writeLine(writer *bufio.Writer, e *element) error {
+	if _, err := writer.WriteString(e.line); err != nil {
+		return err
+	}
+	return writer.WriteByte('\n')
+}
+
+// element answers a new element for the most recently read line, or nil if the line
+// is to be skipped because its key is not a time.
+func (s *sorter) element(line string) (*element, error) {
+	if len(s.slab) == 0 {
+		s.slab = make([]element, batchSize)
+	}
+	e := &s.slab[0]
+	s.slab = s.slab[1:]
+	*e = element{key: line, line: line, ordinal: s.stats.Read}
+	if s.key != nil {
+		e.key = s.key(line)
+	}
+	if s.time == nil {
+		return e, nil
+	}
+
+	// like the numbers of sort(1), times are parsed without the leading blanks of the key
+	at, err := s.time(strings.TrimLeft(e.key, " \t"))
+	if err == nil {
+		// the time replaces the key, so lines with equal times retain their input order
+		e.at, e.key = at, ""
+		return e, nil
+	}
+	s.stats.Unparseable++
+	switch s.policy {
+	case skipPolicy:
+		return nil, nil
+	case endPolicy:
+		e.at, e.key = endOfTime, ""
+		return e, nil
+	default:
+		return nil, fmt.Errorf("line %d: cannot parse time from %q: %v", s.stats.Read, e.key, err)
+	}
+}
+
+// add adds a sorted batch of elements to the buffer, spilling the buffer once the budget is
+// exceeded. Since a batch of nearly sorted input mostly sorts after the elements that the buffer
+// already holds, it is mostly appended to them.
+func (s *sorter) add(elements []*element) error {
+	s.added = s.added[0:0]
+	for _, e := range elements {
+		s.added = append(s.added, e)
+		s.used += len(e.line) + elementOverhead
+	}
+	if err := s.buffer.Add(s.added); err != nil {
+		return err
+	}
+	if s.used > s.budget {
+		return s.spill()
+	}
+	return nil
+}
+
+// sortBatch sorts a batch of elements by insertion, with comparisons that are not dispatched
+// through the Element interface.
+func sortBatch(elements []*element) {
+	for n, e := range elements {
+		i := insertionPoint(elements[0:n], e)
+		copy(elements[i+1:n+1], elements[i:n])
+		elements[i] = e
+	}
+}
+
+// insertionPoint answers the index of the first of the sorted elements that e sorts before.
+// The search gallops backwards from the last element, since a line is rarely much later than
+// the lines read just before it. The elements are distinct, so none is equal to e.
+func insertionPoint(sorted []*element, e *element) int {
+	// e sorts before sorted[hi], if hi < len(sorted), and after sorted[lo], if lo >= 0
+	hi := len(sorted)
+	lo := hi - 8
+	for step := 16; lo >= 0 && e.compare(sorted[lo]) < 0; step *= 2 {
+		hi = lo
+		lo = hi - step
+	}
+	if lo < -1 {
+		lo = -1
+	}
+	return lo + 1 + sort.Search(hi-lo-1, func(k int) bool {
+		return e.compare(sorted[lo+1+k]) < 0
+	})
+}
+
+// spill freezes the buffer and writes it to a new segment file, which replaces
+// the buffer as one of the sorted runs.
+func (s *sorter) spill() error {
+	if s.dir == "" {
+		dir, err := os.MkdirTemp(s.tmpdir, "tsl-sort-")
+		if err != nil {
+			return err
+		}
+		s.dir = dir
+	}
+
+	size, err := s.write(s.buffer.Freeze(), 0)
+	if err != nil {
+		return err
+	}
+	s.stats.Spills++
+	s.stats.SpilledBytes += size
+	s.buffer = tsl.NewUnsortedRange()
+	s.used = 0
+	return s.compact()
+}
+
+// compact merges the most recent runs into a new run while there are fanIn runs, so that
+// the number of open spill files, and of the runs of the final merge, is bounded. The runs
+// merged are those that have been merged as many times as the most recent run, or, if it is
+// the only such run, those that have been merged one more time, so each element is only
+// rewritten once for each level of merging, and the number of levels grows with the
+// logarithm of the number of spills.
+func (s *sorter) compact() error {
+	for len(s.runs) >= fanIn {
+		n := len(s.runs)
+		i := n - 1
+		for i > 0 && s.levels[i-1] == s.levels[n-1] {
+			i--
+		}
+		if i == n-1 {
+			for i > 0 && s.levels[i-1] == s.levels[n-2] {
+				i--
+			}
+		}
+
+		level := s.levels[i] + 1
+		merged, files := mergedRuns(s.runs[i:]), s.files[i:]
+		if _, err := s.write(merged, level); err != nil {
+			return err
+		}
+		// the merged runs are replaced by the new run, which is the last
+		s.runs = append(s.runs[0:i], s.runs[len(s.runs)-1])
+		s.levels = append(s.levels[0:i], level)
+		last := s.files[len(s.files)-1]
+		for _, f := range files {
+			if err := f.Close(); err != nil {
+				return err
+			}
+			if err := os.Remove(f.Name()); err != nil {
+				return err
+			}
+		}
+		s.files = append(s.files[0:i], last)
+		s.stats.Merges++
+	}
+	return nil
+}
+
+// write writes a sorted range to a new segment file in the temporary directory and opens
+// the file as a sorted run that has been merged level times, answering the size of the file.
+func (s *sorter) write(r tsl.SortedRange, level int) (int64, error) {
+	name := filepath.Join(s.dir, fmt.Sprintf("run-%06d.seg", s.stats.Spills+s.stats.Merges))
+	if err := tsl.CreateSegmentFile(name, r, s.codec()); err != nil {
+		return 0, err
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return 0, err
+	}
+	run, err := tsl.OpenSegment(file, fi.Size(), s.codec())
+	if err != nil {
+		file.Close()
+		return 0, err
+	}
+
+	s.runs = append(s.runs, run)
+	s.files = append(s.files, file)
+	s.levels = append(s.levels, level)
+	return fi.Size(), nil
+}
+
+// codec answers the codec used to encode spilled elements.
+func (s *sorter) codec() lineCodec {
+	return lineCodec{timed: s.time != nil}
+}
+
+// cleanup closes and removes the spill files.
+func (s *sorter) cleanup() error {
+	var err error
+	for _, f := range s.files {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	s.files = nil
+	if s.dir != "" {
+		if rerr := os.RemoveAll(s.dir); err == nil {
+			err = rerr
+		}
+		s.dir = ""
+	}
+	return err
+}
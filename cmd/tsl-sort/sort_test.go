@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wildducktheories/timeserieslog"
+)
+
+// checkSort checks that sorting lines with the specified budget produces the same
+// output as sort.Strings and that no spill files are left behind.
+func checkSort(t *testing.T, lines []string, budget int) *sorter {
+	tmpdir := t.TempDir()
+	s := &sorter{budget: budget, tmpdir: tmpdir}
+	out := &bytes.Buffer{}
+	if err := s.sort(strings.NewReader(strings.Join(lines, "\n")+"\n"), out); err != nil {
+		t.Fatalf("sort failed. got: %v, expected: nil", err)
+	}
+
+	expected := append([]string{}, lines...)
+	sort.Strings(expected)
+	got := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("sorted output. got: %v, expected: %v", got, expected)
+	}
+
+	if entries, _ := os.ReadDir(tmpdir); len(entries) != 0 {
+		t.Fatalf("spill files should be removed. got: %v, expected: []", entries)
+	}
+	return s
+}
+
+func Test_Sort_InMemory(t *testing.T) {
+	lines := []string{"c", "a", "b", "a", "d"}
+	s := checkSort(t, lines, 1<<20)
+	if s.stats.Spills != 0 {
+		t.Fatalf("spills. got: %d, expected: 0", s.stats.Spills)
+	}
+}
+
+// seededRand answers a source of random numbers with a new seed, which is logged so that
+// a failure can be reproduced.
+func seededRand(t testing.TB) *rand.Rand {
+	seed := time.Now().UnixNano()
+	t.Logf("seed: %d", seed)
+	return rand.New(rand.NewSource(seed))
+}
+
+func Test_Sort_Spilled(t *testing.T) {
+	lines := []string{}
+	for _, i := range seededRand(t).Perm(10000) {
+		lines = append(lines, fmt.Sprintf("%08d", i%5000))
+	}
+	s := checkSort(t, lines, 64*1024)
+	if s.stats.Spills == 0 {
+		t.Fatalf("spills. got: 0, expected: > 0")
+	}
+}
+
+func Test_Sort_FanIn(t *testing.T) {
+	lines := []string{}
+	for _, i := range seededRand(t).Perm(50000) {
+		lines = append(lines, fmt.Sprintf("%08d", i))
+	}
+	s := checkSort(t, lines, 64*1024)
+	if s.stats.Spills <= 2*fanIn || s.stats.Merges == 0 || len(s.runs) >= fanIn {
+		t.Fatalf("spills, merges, runs. got: %d, %d, %d, expected: > %d, > 0, < %d", s.stats.Spills, s.stats.Merges, len(s.runs), 2*fanIn, fanIn)
+	}
+}
+
+func Test_Sort_NearlySorted(t *testing.T) {
+	r := seededRand(t)
+	lines := []string{}
+	for i := 0; i < 10000; i++ {
+		lines = append(lines, fmt.Sprintf("%08d", i+r.Intn(50)))
+	}
+	checkSort(t, lines, 32*1024)
+}
+
+func Test_Sort_Stable(t *testing.T) {
+	s := &sorter{budget: elementOverhead, tmpdir: t.TempDir()}
+	out := &bytes.Buffer{}
+	if err := s.sort(strings.NewReader("b\na\nb\na"), out); err != nil {
+		t.Fatalf("sort failed. got: %v, expected: nil", err)
+	}
+	if got, expected := out.String(), "a\na\nb\nb\n"; got != expected {
+		t.Fatalf("sorted output. got: %q, expected: %q", got, expected)
+	}
+	if s.stats.Read != 4 {
+		t.Fatalf("read. got: %d, expected: 4", s.stats.Read)
+	}
+}
+
+// nearlySortedLines answers n lines of zero padded numbers that are each displaced by up
+// to 100 lines from their sorted position.
+func nearlySortedLines(n int) string {
+	r := rand.New(rand.NewSource(1))
+	b := &strings.Builder{}
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(b, "%010d\n", i+r.Intn(100))
+	}
+	return b.String()
+}
+
+func benchmarkSort(b *testing.B, budget int) {
+	input := nearlySortedLines(1000000)
+	b.SetBytes(int64(len(input)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s := &sorter{budget: budget, tmpdir: b.TempDir()}
+		if err := s.sort(strings.NewReader(input), io.Discard); err != nil {
+			b.Fatalf("sort failed. got: %v, expected: nil", err)
+		}
+	}
+}
+
+func Benchmark_Sort_NearlySorted_InMemory(b *testing.B) {
+	benchmarkSort(b, 1<<30)
+}
+
+func Benchmark_Sort_NearlySorted_Spilled(b *testing.B) {
+	benchmarkSort(b, 8<<20)
+}
+
+// Benchmark_StreamMerge merges the runs that a spilling sort of nearly sorted input
+// produces, which barely overlap.
+func Benchmark_StreamMerge(b *testing.B) {
+	s := &sorter{budget: 8 << 20, tmpdir: b.TempDir()}
+	defer s.cleanup()
+	if err := s.fill(strings.NewReader(nearlySortedLines(1000000))); err != nil {
+		b.Fatalf("unexpected error: %v", err)
+	}
+	runs := append(s.runs, s.buffer.Freeze())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		n := 0
+		if err := streamMerge(runs, func(e *element) error { n++; return nil }); err != nil {
+			b.Fatalf("unexpected error: %v", err)
+		}
+	}
+	b.ReportMetric(float64(len(runs)), "runs")
+}
+
+// failingWriter fails every write once limit bytes have been written.
+type failingWriter struct {
+	limit int
+}
+
+var errWrite = errors.New("write failed")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.limit {
+		n := w.limit
+		w.limit = 0
+		return n, errWrite
+	}
+	w.limit -= len(p)
+	return len(p), nil
+}
+
+func Test_Sort_FailingWriter(t *testing.T) {
+	input := nearlySortedLines(100000)
+	s := &sorter{budget: 256 * 1024, tmpdir: t.TempDir()}
+	if err := s.sort(strings.NewReader(input), &failingWriter{limit: 1000}); err != errWrite {
+		t.Fatalf("sort. got: %v, expected: %v", err, errWrite)
+	}
+
+	// the merge stops at the first failed write
+	runs := []tsl.SortedRange{}
+	for _, lines := range [][]string{{"a", "c", "e"}, {"b", "d", "f"}} {
+		u := tsl.NewUnsortedRange()
+		for i, line := range lines {
+			u.Add([]tsl.Element{&element{key: line, line: line, ordinal: i}})
+		}
+		runs = append(runs, u.Freeze())
+	}
+	written := 0
+	err := streamMerge(runs, func(e *element) error {
+		if written++; written == 3 {
+			return errWrite
+		}
+		return nil
+	})
+	if err != errWrite || written != 3 {
+		t.Fatalf("merge. got: %v after %d writes, expected: %v after 3 writes", err, written, errWrite)
+	}
+}
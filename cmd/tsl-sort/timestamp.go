@@ -0,0 +1,216 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeFunc parses a key into a time.
+type timeFunc func(key string) (time.Time, error)
+
+// policy determines what happens to lines whose key cannot be parsed as a time.
+type policy int
+
+const (
+	failPolicy policy = iota // stop the sort with an error
+	skipPolicy               // discard the line
+	endPolicy                // sort the line after all lines with a time, in input order
+)
+
+// endOfTime is the time given to unparseable lines that sort to the end of the output.
+var endOfTime = time.Unix(math.MaxInt64>>1, 0)
+
+var errBadEpoch = errors.New("invalid epoch time")
+
+// parsePolicy parses the name of a policy.
+func parsePolicy(name string) (policy, error) {
+	switch name {
+	case "fail":
+		return failPolicy, nil
+	case "skip":
+		return skipPolicy, nil
+	case "end":
+		return endPolicy, nil
+	default:
+		return failPolicy, fmt.Errorf("invalid unparseable line policy: %q", name)
+	}
+}
+
+// autoLayouts are the layouts tried, in order, by the auto time format after epoch times.
+var autoLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999 -0700",
+	"2006-01-02 15:04:05.999999999",
+	"02/Jan/2006:15:04:05 -0700",
+	time.RFC1123Z,
+	time.RFC1123,
+	time.UnixDate,
+	time.ANSIC,
+}
+
+// newTimeFunc answers a timeFunc for a time format, which is one of RFC3339,
+// RFC3339Nano, unix, unixms, unixns, auto, a strftime layout containing % directives or
+// a Go time layout. Times without a zone are parsed as UTC.
+func newTimeFunc(format string) (timeFunc, error) {
+	switch format {
+	case "RFC3339":
+		return layoutTime(time.RFC3339), nil
+	case "RFC3339Nano":
+		return layoutTime(time.RFC3339Nano), nil
+	case "unix":
+		return epochTime(time.Second), nil
+	case "unixms":
+		return epochTime(time.Millisecond), nil
+	case "unixns":
+		return epochTime(time.Nanosecond), nil
+	case "auto":
+		return autoTime(), nil
+	}
+
+	layout := format
+	if strings.Contains(format, "%") {
+		var err error
+		if layout, err = strftimeLayout(format); err != nil {
+			return nil, err
+		}
+	}
+	// a layout without any elements formats every time as itself
+	sample := time.Date(1999, time.December, 31, 23, 59, 58, 0, time.UTC)
+	if sample.Format(layout) == layout {
+		return nil, fmt.Errorf("invalid time format: %q", format)
+	}
+	return layoutTime(layout), nil
+}
+
+// layoutTime answers a timeFunc that parses times with a Go time layout.
+func layoutTime(layout string) timeFunc {
+	return func(key string) (time.Time, error) {
+		return time.Parse(layout, key)
+	}
+}
+
+// epochTime answers a timeFunc that parses a decimal number of units since the
+// Unix epoch. The number may have a fractional part.
+func epochTime(unit time.Duration) timeFunc {
+	return func(key string) (time.Time, error) {
+		return parseEpoch(key, unit)
+	}
+}
+
+// parseEpoch parses a decimal number of units since the Unix epoch.
+func parseEpoch(key string, unit time.Duration) (time.Time, error) {
+	whole, fraction, _ := strings.Cut(key, ".")
+	n, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || whole[0] == '+' {
+		return time.Time{}, errBadEpoch
+	}
+	seconds, nanos := n/int64(time.Second/unit), (n%int64(time.Second/unit))*int64(unit)
+	if fraction != "" {
+		if len(fraction) > 9 {
+			fraction = fraction[0:9]
+		}
+		f, err := strconv.ParseUint(fraction, 10, 64)
+		if err != nil || fraction[0] == '+' {
+			return time.Time{}, errBadEpoch
+		}
+		for i := len(fraction); i < 9; i++ {
+			f *= 10
+		}
+		part := int64(f) * int64(unit) / int64(time.Second)
+		if whole[0] == '-' {
+			part = -part
+		}
+		nanos += part
+	}
+	return time.Unix(seconds, nanos).UTC(), nil
+}
+
+// autoTime answers a timeFunc that detects the format of each key. Integers are
+// treated as epoch times whose unit is determined by their magnitude, so that seconds,
+// milliseconds, microseconds and nanoseconds since the epoch may be mixed. Other keys
+// are parsed with each of the autoLayouts, starting with the last one that succeeded.
+func autoTime() timeFunc {
+	last := 0
+	return func(key string) (time.Time, error) {
+		if n, err := strconv.ParseInt(key, 10, 64); err == nil {
+			abs := n
+			if abs < 0 {
+				abs = -abs
+			}
+			switch {
+			case abs < 1e11:
+				return time.Unix(n, 0).UTC(), nil
+			case abs < 1e14:
+				return time.UnixMilli(n).UTC(), nil
+			case abs < 1e17:
+				return time.UnixMicro(n).UTC(), nil
+			default:
+				return time.Unix(0, n).UTC(), nil
+			}
+		}
+		if t, err := parseEpoch(key, time.Second); err == nil {
+			return t, nil
+		}
+		for i := range autoLayouts {
+			j := (last + i) % len(autoLayouts)
+			if t, err := time.Parse(autoLayouts[j], key); err == nil {
+				last = j
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("unrecognized time format")
+	}
+}
+
+// strftimeDirectives maps strftime directives to the equivalent Go layout elements.
+var strftimeDirectives = map[byte]string{
+	'a': "Mon",
+	'A': "Monday",
+	'b': "Jan",
+	'B': "January",
+	'd': "02",
+	'D': "01/02/06",
+	'e': "_2",
+	'F': "2006-01-02",
+	'h': "Jan",
+	'H': "15",
+	'I': "03",
+	'j': "002",
+	'm': "01",
+	'M': "04",
+	'p': "PM",
+	'S': "05",
+	'T': "15:04:05",
+	'y': "06",
+	'Y': "2006",
+	'z': "-0700",
+	'Z': "MST",
+	'%': "%",
+}
+
+// strftimeLayout converts a strftime layout into a Go time layout.
+func strftimeLayout(format string) (string, error) {
+	b := strings.Builder{}
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			b.WriteByte(format[i])
+			continue
+		}
+		i++
+		if i == len(format) {
+			return "", fmt.Errorf("invalid time format: %q", format)
+		}
+		directive, ok := strftimeDirectives[format[i]]
+		if !ok {
+			return "", fmt.Errorf("unsupported directive %%%c in time format: %q", format[i], format)
+		}
+		b.WriteString(directive)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// checkTimes checks that the timeFunc for format parses each key as the expected time.
+func checkTimes(t *testing.T, format string, expected map[string]time.Time) {
+	parse, err := newTimeFunc(format)
+	if err != nil {
+		t.Fatalf("newTimeFunc(%q). got: %v, expected: nil", format, err)
+	}
+	for key, at := range expected {
+		got, err := parse(key)
+		if err != nil || !got.Equal(at) {
+			t.Fatalf("%s time of %q. got: %v, %v, expected: %v", format, key, got, err, at)
+		}
+	}
+}
+
+func Test_Time_Named(t *testing.T) {
+	at := time.Date(2016, time.March, 6, 22, 26, 43, 0, time.UTC)
+	checkTimes(t, "RFC3339", map[string]time.Time{
+		"2016-03-06T22:26:43Z":      at,
+		"2016-03-07T09:26:43+11:00": at,
+	})
+	checkTimes(t, "RFC3339Nano", map[string]time.Time{
+		"2016-03-06T22:26:43.5Z": at.Add(500 * time.Millisecond),
+	})
+	checkTimes(t, "unix", map[string]time.Time{
+		"1457303203":      at,
+		"1457303203.25":   at.Add(250 * time.Millisecond),
+		"5":               time.Unix(5, 0),
+		"-1.5":            time.Unix(-2, 500000000),
+		"0.0000000019999": time.Unix(0, 1),
+	})
+	checkTimes(t, "unixms", map[string]time.Time{
+		"1457303203250":   at.Add(250 * time.Millisecond),
+		"1457303203250.5": at.Add(250*time.Millisecond + 500*time.Microsecond),
+	})
+	checkTimes(t, "unixns", map[string]time.Time{
+		"1457303203000000001": at.Add(1),
+	})
+}
+
+func Test_Time_Layouts(t *testing.T) {
+	at := time.Date(2016, time.March, 6, 22, 26, 43, 0, time.UTC)
+	checkTimes(t, "02/Jan/2006:15:04:05 -0700", map[string]time.Time{
+		"06/Mar/2016:14:26:43 -0800": at,
+	})
+	checkTimes(t, "%d/%b/%Y:%H:%M:%S %z", map[string]time.Time{
+		"06/Mar/2016:14:26:43 -0800":    at,
+		"06/Mar/2016:14:26:43.25 -0800": at.Add(250 * time.Millisecond),
+	})
+	checkTimes(t, "%F %T", map[string]time.Time{
+		"2016-03-06 22:26:43": at,
+	})
+	for _, format := range []string{"no elements", "%Q", "%Y%"} {
+		if _, err := newTimeFunc(format); err == nil {
+			t.Fatalf("newTimeFunc(%q) should fail", format)
+		}
+	}
+}
+
+func Test_Time_Auto(t *testing.T) {
+	at := time.Date(2016, time.March, 6, 22, 26, 43, 0, time.UTC)
+	checkTimes(t, "auto", map[string]time.Time{
+		"1457303203":                      at,
+		"1457303203.5":                    at.Add(500 * time.Millisecond),
+		"1457303203000":                   at,
+		"1457303203000000":                at,
+		"1457303203000000000":             at,
+		"2016-03-06T22:26:43Z":            at,
+		"2016-03-06 22:26:43":             at,
+		"06/Mar/2016:14:26:43 -0800":      at,
+		"Sun, 06 Mar 2016 22:26:43 +0000": at,
+	})
+	parse, _ := newTimeFunc("auto")
+	if _, err := parse("yesterday"); err == nil {
+		t.Fatalf("auto should not parse %q", "yesterday")
+	}
+}
+
+func Test_Time_Policy(t *testing.T) {
+	for name, expected := range map[string]policy{"fail": failPolicy, "skip": skipPolicy, "end": endPolicy} {
+		if got, err := parsePolicy(name); err != nil || got != expected {
+			t.Fatalf("parsePolicy(%q). got: %v, %v, expected: %v", name, got, err, expected)
+		}
+	}
+	if _, err := parsePolicy("ignore"); err == nil {
+		t.Fatalf("parsePolicy(%q) should fail", "ignore")
+	}
+}
+
+func Test_Sort_Timed(t *testing.T) {
+	input := []string{
+		"2016-03-06T22:26:45Z c",
+		"garbage",
+		"2016-03-07T09:26:43+11:00 a",
+		"1457303204 b",
+		"2016-03-06T22:26:43Z d",
+		"junk",
+	}
+	expected := map[policy][]string{
+		skipPolicy: {input[2], input[4], input[3], input[0]},
+		endPolicy:  {input[2], input[4], input[3], input[0], input[1], input[5]},
+	}
+
+	parse, _ := newTimeFunc("auto")
+	for p, lines := range expected {
+		for _, budget := range []int{1 << 20, elementOverhead} {
+			s := &sorter{budget: budget, tmpdir: t.TempDir(), key: fieldKey("", 1, 1, false), time: parse, policy: p}
+			out := &bytes.Buffer{}
+			if err := s.sort(strings.NewReader(strings.Join(input, "\n")), out); err != nil {
+				t.Fatalf("sort failed. got: %v, expected: nil", err)
+			}
+			got := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+			if !reflect.DeepEqual(got, lines) {
+				t.Fatalf("timed sort with policy %v and budget %d. got: %q, expected: %q", p, budget, got, lines)
+			}
+			if s.stats.Unparseable != 2 {
+				t.Fatalf("unparseable. got: %d, expected: 2", s.stats.Unparseable)
+			}
+		}
+	}
+
+	s := &sorter{budget: 1 << 20, tmpdir: t.TempDir(), key: fieldKey("", 1, 1, false), time: parse, policy: failPolicy}
+	if err := s.sort(strings.NewReader(strings.Join(input, "\n")), &bytes.Buffer{}); err == nil || !strings.HasPrefix(err.Error(), "line 2:") {
+		t.Fatalf("fail policy. got: %v, expected: line 2: ...", err)
+	}
+
+	// the leading blanks of a field are not part of its time
+	s = &sorter{budget: 1 << 20, tmpdir: t.TempDir(), key: fieldKey("", 2, 2, false), time: parse, policy: failPolicy}
+	out := &bytes.Buffer{}
+	if err := s.sort(strings.NewReader("c  1457303205\na 1457303203\nb\t1457303204\n"), out); err != nil || out.String() != "a 1457303203\nb\t1457303204\nc  1457303205\n" {
+		t.Fatalf("blank separated times. got: %q, %v", out.String(), err)
+	}
+}
+
+func Test_LineCodec_Timed(t *testing.T) {
+	codec := lineCodec{timed: true}
+	for _, e := range []*element{
+		{at: time.Unix(1457303203, 250), key: "", line: "a line", ordinal: 1},
+		{at: time.Unix(-5, 1), key: "", line: "", ordinal: 2},
+		{at: endOfTime, key: "", line: "junk", ordinal: 3},
+	} {
+		data, _ := codec.Encode(e)
+		got, err := codec.Decode(data)
+		if err != nil {
+			t.Fatalf("codec round trip. got: %v, expected: nil", err)
+		}
+		ge := got.(*element)
+		if !ge.at.Equal(e.at) || ge.line != e.line || ge.key != e.key || ge.ordinal != e.ordinal {
+			t.Fatalf("codec round trip. got: %v, expected: %v", ge, e)
+		}
+	}
+}
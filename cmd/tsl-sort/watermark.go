@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/wildducktheories/timeserieslog"
+)
+
+// lateAction determines what the watermark mode does with lines that are later than the
+// allowed lateness.
+type lateAction int
+
+const (
+	mergeLate lateAction = iota // write the line out of order and warn
+	dropLate                    // discard the line
+	sideLate                    // write the line to the late output
+)
+
+// parseLateAction parses the name of a lateAction.
+func parseLateAction(name string) (lateAction, error) {
+	switch name {
+	case "merge":
+		return mergeLate, nil
+	case "drop":
+		return dropLate, nil
+	case "side":
+		return sideLate, nil
+	default:
+		return mergeLate, fmt.Errorf("invalid late line action: %q", name)
+	}
+}
+
+// watermarked reads lines from in and writes them to out in sorted order, using a
+// tsl.Watermark to write each line as soon as the latest time read, less the allowed
+// lateness, passes it. Only the lines within the allowed lateness are held in memory, so
+// nothing is spilled, regardless of the size of the input. Lines with unparseable times
+// that are sorted to the end are held until the input is exhausted.
+//
+// The output is flushed whenever lines are written, and the lines read so far are added
+// to the watermark whenever reading the next line might block, so that lines are written
+// as soon as the watermark passes them even if the input, such as the output of tail -f,
+// arrives slowly.
+func (s *sorter) watermarked(in io.Reader, out io.Writer) error {
+	writer := bufio.NewWriterSize(out, 1<<16)
+	write := func(r tsl.SortedRange) error {
+		if r.Limit() == 0 {
+			return nil
+		}
+		for e := range tsl.All(r) {
+			if err := writeLine(writer, e.(*element)); err != nil {
+				return err
+			}
+		}
+		return writer.Flush()
+	}
+
+	var lateWriter *bufio.Writer
+	var lateErr error // the first error writing to lateWriter
+	if s.lateAction == sideLate {
+		lateWriter = bufio.NewWriterSize(s.lateOutput, 1<<16)
+	}
+
+	options := tsl.WatermarkOptions{
+		Lateness: s.lateness,
+		Time: func(e tsl.Element) time.Time {
+			return e.(*element).at
+		},
+		Probe: func(t time.Time) tsl.Element {
+			// ordinals start at 1, so the probe sorts before every element at t
+			return &element{at: t}
+		},
+		Policy: tsl.SideOutputLate,
+		Late: func(late tsl.SortedRange) {
+			for e := range tsl.All(late) {
+				s.stats.Late++
+				if lateWriter != nil && lateErr == nil {
+					lateErr = writeLine(lateWriter, e.(*element))
+				}
+			}
+		},
+	}
+	if s.lateAction == mergeLate {
+		options.Policy = tsl.MergeLate
+		options.Late = func(late tsl.SortedRange) {
+			n := tsl.Count(late)
+			s.stats.Late += n
+			fmt.Fprintf(s.warnings, "warning: %d line(s) more than %v late written out of order before line %d\n", n, s.lateness, s.stats.Read)
+		}
+	}
+	w := tsl.NewWatermark(options)
+
+	// add adds the batch to the watermark and writes the lines that the watermark passes
+	add := func(batch []tsl.Element) error {
+		if err := write(w.Add(batch)); err != nil {
+			return err
+		}
+		return lateErr
+	}
+
+	var batch, tail []tsl.Element
+	err := s.read(in, func(e *element) error {
+		if e.at == endOfTime {
+			tail = append(tail, e)
+			return nil
+		}
+		batch = append(batch, e)
+		if len(batch) == batchSize {
+			if err := add(batch); err != nil {
+				return err
+			}
+			batch = batch[0:0]
+		}
+		return nil
+	}, func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := add(batch); err != nil {
+			return err
+		}
+		batch = batch[0:0]
+		if lateWriter != nil {
+			return lateWriter.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := add(batch); err != nil {
+		return err
+	}
+	if err := write(w.Flush()); err != nil {
+		return err
+	}
+	for _, e := range tail {
+		if err := writeLine(writer, e.(*element)); err != nil {
+			return err
+		}
+	}
+
+	if lateWriter != nil {
+		if err := lateWriter.Flush(); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
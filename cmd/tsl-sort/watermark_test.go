@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// watermarkSort sorts input in watermark mode with a lateness of 10 seconds and answers
+// the output, the late output and the warnings.
+func watermarkSort(t *testing.T, input []string, action lateAction, unparseable policy) (*sorter, []string, string, string) {
+	parse, _ := newTimeFunc("unix")
+	out, lateOutput, warnings := &bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}
+	s := &sorter{
+		key:        fieldKey("", 1, 1, false),
+		time:       parse,
+		policy:     unparseable,
+		lateness:   10 * time.Second,
+		lateAction: action,
+		lateOutput: lateOutput,
+		warnings:   warnings,
+	}
+	if err := s.watermarked(strings.NewReader(strings.Join(input, "\n")), out); err != nil {
+		t.Fatalf("watermark sort failed. got: %v, expected: nil", err)
+	}
+	return s, strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n"), lateOutput.String(), warnings.String()
+}
+
+func join(slices ...[]string) []string {
+	result := []string{}
+	for _, s := range slices {
+		result = append(result, s...)
+	}
+	return result
+}
+
+func Test_Watermark_Actions(t *testing.T) {
+	// the first batch advances the watermark to 2013, so the late lines in the second
+	// batch are more than 10 seconds late
+	input, sorted := []string{}, []string{}
+	for i := 0; i < batchSize; i++ {
+		input = append(input, strconv.Itoa(1000+i))
+		sorted = append(sorted, strconv.Itoa(1000+i))
+	}
+	input = append(input, "2030 a", "1500 late", "x", "2025 b", "2012 late", "2013 c")
+
+	// sorted[1013] is "2013", which sorts before "2013 c" because it was read first
+	expected := join(sorted[0:1014], []string{"2013 c"}, sorted[1014:], []string{"2025 b", "2030 a", "x"})
+	s, got, lateOutput, warnings := watermarkSort(t, input, dropLate, endPolicy)
+	if !reflect.DeepEqual(got, expected) || s.stats.Late != 2 || lateOutput != "" || warnings != "" {
+		t.Fatalf("drop. got: %q, %d, expected: %q, 2", got[batchSize:], s.stats.Late, expected[batchSize:])
+	}
+
+	_, got, lateOutput, _ = watermarkSort(t, input, sideLate, endPolicy)
+	if !reflect.DeepEqual(got, expected) || lateOutput != "1500 late\n2012 late\n" {
+		t.Fatalf("side. got: %q, %q, expected: %q", got[batchSize:], lateOutput, expected[batchSize:])
+	}
+
+	// merged late lines are written with the lines emitted by the same batch, after the
+	// lines before the watermark that were emitted by the first batch
+	s, got, _, warnings = watermarkSort(t, input, mergeLate, skipPolicy)
+	expected = join(sorted[0:1013], []string{"1500 late", "2012 late", "2013", "2013 c"}, sorted[1014:], []string{"2025 b", "2030 a"})
+	if !reflect.DeepEqual(got, expected) || s.stats.Late != 2 || !strings.HasPrefix(warnings, "warning: 2 line(s)") {
+		t.Fatalf("merge. got: %q, %q, expected: %q", got[batchSize-12:], warnings, expected[batchSize-12:])
+	}
+}
+
+func Test_Watermark_Progressive(t *testing.T) {
+	input, expected := []string{}, []string{}
+	for i := 0; i < 3*batchSize; i++ {
+		// each pair of lines is swapped, so no line is more than a second late
+		input = append(input, strconv.Itoa(1000+(i^1)))
+		expected = append(expected, strconv.Itoa(1000+i))
+	}
+	s, got, _, _ := watermarkSort(t, input, dropLate, failPolicy)
+	if !reflect.DeepEqual(got, expected) || s.stats.Late != 0 {
+		t.Fatalf("progressive. got: %d lines, %d late, expected: %d lines, 0 late", len(got), s.stats.Late, len(expected))
+	}
+}
+
+// countingReader counts the bytes read from a reader.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func Test_Watermark_FailingWriter(t *testing.T) {
+	b := &strings.Builder{}
+	for i := 0; i < 1000000; i++ {
+		fmt.Fprintf(b, "%d\n", 1000000000+i)
+	}
+	parse, _ := newTimeFunc("unix")
+	s := &sorter{
+		key:      fieldKey("", 1, 1, false),
+		time:     parse,
+		lateness: 10 * time.Second,
+		warnings: io.Discard,
+	}
+	in := &countingReader{r: strings.NewReader(b.String())}
+	if err := s.watermarked(in, &failingWriter{limit: 1000}); err != errWrite {
+		t.Fatalf("watermark sort. got: %v, expected: %v", err, errWrite)
+	}
+	if in.n == b.Len() {
+		t.Fatalf("the watermark sort should stop reading at the first failed write")
+	}
+}
+
+func Test_Watermark_Streaming(t *testing.T) {
+	parse, _ := newTimeFunc("unix")
+	s := &sorter{
+		key:      fieldKey("", 1, 1, false),
+		time:     parse,
+		lateness: 10 * time.Second,
+		warnings: io.Discard,
+	}
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := s.watermarked(inReader, outWriter)
+		outWriter.CloseWithError(err)
+		done <- err
+	}()
+
+	// the lines before the watermark are written while the input is still open
+	if _, err := io.WriteString(inWriter, "1\n3\n2\n100\n"); err != nil {
+		t.Fatalf("write. got: %v, expected: nil", err)
+	}
+	lines := make(chan string)
+	go func() {
+		buffer := make([]byte, 6)
+		n, _ := io.ReadFull(outReader, buffer)
+		lines <- string(buffer[:n])
+	}()
+	select {
+	case got := <-lines:
+		if got != "1\n2\n3\n" {
+			t.Fatalf("streaming. got: %q, expected: %q", got, "1\n2\n3\n")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("the lines before the watermark should be written before the input is closed")
+	}
+
+	inWriter.Close()
+	rest, _ := io.ReadAll(outReader)
+	if err := <-done; err != nil || string(rest) != "100\n" {
+		t.Fatalf("close. got: %q, %v, expected: %q, nil", rest, err, "100\n")
+	}
+}
@@ -0,0 +1,125 @@
+package tsl
+
+// Merging ranges builds a tree in which disjointRanges, mergeableRanges and kwayRanges
+// are the interior nodes and immutableRanges and segment ranges are the leaves. Each
+// level of the tree adds indirection, and each incomplete merge adds locking, to every
+// call to Next, so deep trees are compacted by materializing subtrees into immutableRanges.
+
+// Shape describes the tree of ranges from which a SortedRange is composed. Visible
+// views are transparent, so the shape of a visible view is the shape of the range it views.
+type Shape struct {
+	// Depth is the number of levels in the tree. A range that is not composed of other ranges has a depth of 1.
+	Depth int
+	// Nodes is the number of ranges in the tree, including the leaves.
+	Nodes int
+	// Leaves is the number of ranges in the tree that are not composed of other ranges.
+	Leaves int
+	// MaxFanOut is the largest number of ranges that any range in the tree is composed of.
+	MaxFanOut int
+}
+
+// CompactionPolicy determines when a subtree of ranges is materialized into a single immutableRange.
+// A zero limit is no limit, so the zero CompactionPolicy only compacts merges that are already complete.
+type CompactionPolicy struct {
+	// MaxDepth is the maximum depth of a tree.
+	MaxDepth int
+	// MaxFanOut is the maximum number of ranges that any range in a tree may be composed of.
+	MaxFanOut int
+}
+
+// exceeded answers true if the specified shape is not permitted by the policy.
+func (p CompactionPolicy) exceeded(s Shape) bool {
+	return (p.MaxDepth > 0 && s.Depth > p.MaxDepth) || (p.MaxFanOut > 0 && s.MaxFanOut > p.MaxFanOut)
+}
+
+// node is implemented by the SortedRanges that are composed of other SortedRanges.
+type node interface {
+	SortedRange
+	// children answers the ranges from which the receiver is composed. A merge that is
+	// complete has no children.
+	children() []SortedRange
+}
+
+// merger is implemented by the nodes that merge their children into a slice.
+type merger interface {
+	node
+	// immutable completes the merge, if it is not already complete, and answers an
+	// immutableRange that shares the merged slice.
+	immutable() SortedRange
+}
+
+var leaf = Shape{Depth: 1, Nodes: 1, Leaves: 1}
+
+// ShapeOf answers the shape of the tree of ranges from which r is composed.
+func ShapeOf(r SortedRange) Shape {
+	return Inspect(r).Shape()
+}
+
+// add adds the shape of a child to the shape of its parent.
+func (s *Shape) add(child Shape) {
+	if child.Depth+1 > s.Depth {
+		s.Depth = child.Depth + 1
+	}
+	s.Nodes += child.Nodes
+	s.Leaves += child.Leaves
+	if child.MaxFanOut > s.MaxFanOut {
+		s.MaxFanOut = child.MaxFanOut
+	}
+}
+
+// Compact answers a range with the same elements as r, including any Tombstones, that is
+// not composed of other ranges: an immutableRange, unless r is already such a range, such as
+// a segment range, in which case r is answered unchanged. The merges within r are completed
+// in the process.
+func Compact(r SortedRange) SortedRange {
+	return CompactWith(r, CompactionPolicy{MaxDepth: 1})
+}
+
+// CompactWith answers a SortedRange with the same elements as r in which each merge that is
+// complete is replaced by an immutableRange over the merged slice and each subtree whose
+// shape exceeds the policy is materialized into an immutableRange. Subtrees are compacted
+// before the ranges that contain them, so a range is only materialized if compacting its
+// children is not enough to satisfy the policy. The exception is a merge that is not yet
+// complete, whose children are consumed by the merge and so cannot be replaced: it is either
+// materialized, if its shape exceeds the policy, or retained with its children as they are.
+// The visible views within r are retained.
+func CompactWith(r SortedRange, policy CompactionPolicy) SortedRange {
+	compacted, _ := compact(r, policy)
+	return compacted
+}
+
+// compact answers the compacted range and its shape.
+func compact(r SortedRange, policy CompactionPolicy) (SortedRange, Shape) {
+	switch t := r.(type) {
+	case *visibleRange:
+		underlying, s := compact(t.underlying, policy)
+		return Visible(underlying), s
+	case *disjointRanges:
+		d := &disjointRanges{
+			first:    t.first,
+			last:     t.last,
+			segments: make([]SortedRange, len(t.segments)),
+		}
+		s := Shape{Nodes: 1, MaxFanOut: len(t.segments)}
+		for i, segment := range t.segments {
+			var child Shape
+			d.segments[i], child = compact(segment, policy)
+			s.add(child)
+		}
+		if policy.exceeded(s) {
+			return newImmutableRange(AsSlice(d)), leaf
+		}
+		return d, s
+	case merger:
+		if len(t.children()) == 0 {
+			return t.immutable(), leaf
+		}
+		s := ShapeOf(t)
+		if policy.exceeded(s) {
+			return t.immutable(), leaf
+		}
+		return t, s
+	default:
+		return r, leaf
+	}
+}
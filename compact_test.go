@@ -0,0 +1,156 @@
+package tsl
+
+import (
+	"reflect"
+	"testing"
+)
+
+// deepMerge answers the result of repeatedly merging overlapping ranges into a
+// single range, in the same way as the snapshot loop of toy-tsl-sort.
+func deepMerge(n int) SortedRange {
+	var r SortedRange = EmptyRange
+	for i := 0; i < n; i++ {
+		r = Merge(r, newImmutableRange(NewElements(sequence(i, 10*n, n))))
+	}
+	return r
+}
+
+func Test_ShapeOf(t *testing.T) {
+	r := newImmutableRange(NewElements(sequence(0, 10, 1)))
+	if got, expected := ShapeOf(r), (Shape{Depth: 1, Nodes: 1, Leaves: 1}); got != expected {
+		t.Fatalf("immutable. got: %+v, expected: %+v", got, expected)
+	}
+
+	d := MergeAll(newImmutableRange(NewElements([]int{0, 1})), newImmutableRange(NewElements([]int{2, 3})), newImmutableRange(NewElements([]int{4, 5})))
+	if got, expected := ShapeOf(d), (Shape{Depth: 2, Nodes: 4, Leaves: 3, MaxFanOut: 3}); got != expected {
+		t.Fatalf("disjoint. got: %+v, expected: %+v", got, expected)
+	}
+
+	m := Merge(newImmutableRange(NewElements([]int{0, 2, 3})), newImmutableRange(NewElements([]int{0, 1, 3})))
+	if got, expected := ShapeOf(m), (Shape{Depth: 2, Nodes: 3, Leaves: 2, MaxFanOut: 2}); got != expected {
+		t.Fatalf("mergeable. got: %+v, expected: %+v", got, expected)
+	}
+	AsSlice(m)
+	if got, expected := ShapeOf(Visible(m)), (Shape{Depth: 1, Nodes: 1, Leaves: 1}); got != expected {
+		t.Fatalf("merged. got: %+v, expected: %+v", got, expected)
+	}
+}
+
+func Test_Compact(t *testing.T) {
+	r := deepMerge(8)
+	before := ShapeOf(r)
+	if before.Depth < 4 {
+		t.Fatalf("repeated merges should build a deep tree. got: %+v", before)
+	}
+
+	expected := deepMerge(8)
+	got := Compact(r)
+	if _, ok := got.(*immutableRange); !ok {
+		t.Fatalf("compacted range should be immutable. got: %T", got)
+	}
+	if !reflect.DeepEqual(AsSlice(got), AsSlice(expected)) {
+		t.Fatalf("compact. got: %v, expected: %v", AsSlice(got), AsSlice(expected))
+	}
+	if err := checkSortedRangeInvariants(got); err != nil {
+		t.Fatalf("got: %v. %v", got, err)
+	}
+}
+
+func Test_CompactWith_MaxDepth(t *testing.T) {
+	for depth := 1; depth < 5; depth++ {
+		r := deepMerge(8)
+		got := CompactWith(r, CompactionPolicy{MaxDepth: depth})
+		if s := ShapeOf(got); s.Depth > depth {
+			t.Fatalf("depth. got: %+v, expected: <= %d", s, depth)
+		}
+		if !reflect.DeepEqual(AsSlice(got), AsSlice(deepMerge(8))) {
+			t.Fatalf("compact. got: %v, expected: %v", AsSlice(got), AsSlice(deepMerge(8)))
+		}
+		if err := checkSortedRangeInvariants(got); err != nil {
+			t.Fatalf("got: %v. %v", got, err)
+		}
+	}
+}
+
+func Test_CompactWith_MaxFanOut(t *testing.T) {
+	d := MergeAll(newImmutableRange(NewElements([]int{0, 1})), newImmutableRange(NewElements([]int{2, 3})), newImmutableRange(NewElements([]int{4, 5})))
+	if got := CompactWith(d, CompactionPolicy{MaxFanOut: 3}); got != d && !reflect.DeepEqual(got, d) {
+		t.Fatalf("a range within the policy should not be materialized. got: %v", got)
+	}
+	got := CompactWith(d, CompactionPolicy{MaxFanOut: 2})
+	if s := ShapeOf(got); s.MaxFanOut > 2 {
+		t.Fatalf("fan out. got: %+v, expected: <= 2", s)
+	}
+}
+
+func Test_CompactWith_Merged(t *testing.T) {
+	m := Merge(newImmutableRange(NewElements([]int{0, 2, 3})), newImmutableRange(NewElements([]int{0, 1, 3})))
+	if got := CompactWith(m, CompactionPolicy{}); got != m {
+		t.Fatalf("an incomplete merge should be retained by the zero policy. got: %v", got)
+	}
+	AsSlice(m)
+	if got, ok := CompactWith(m, CompactionPolicy{}).(*immutableRange); !ok || &got.elements[0] != &m.(*mergeableRange).elements[0] {
+		t.Fatalf("a complete merge should be replaced by an immutable range over the merged slice. got: %v", got)
+	}
+}
+
+func Test_Compact_Tombstones(t *testing.T) {
+	r := Merge(newImmutableRange(live(1, 2, 3)), newImmutableRange(tombstones(2)))
+	got := Compact(Visible(r))
+	if _, ok := got.(*visibleRange); !ok {
+		t.Fatalf("visible views should be retained. got: %v", got)
+	}
+	expected := Elements{live(1)[0], tombstones(2)[0], live(3)[0]}
+	if !reflect.DeepEqual(Elements(AsSlice(got.(*visibleRange).underlying)), expected) {
+		t.Fatalf("compact should keep tombstones. got: %v, expected: %v", AsSlice(got.(*visibleRange).underlying), expected)
+	}
+}
+
+func Test_Log_Compaction(t *testing.T) {
+	l := NewLog().(*generationalLog)
+	l.SetCompactionPolicy(CompactionPolicy{MaxDepth: 3})
+	expected := Elements{}
+	for i := 0; i < 20; i++ {
+		l.Append(NewElements(sequence(i, 200, 20)))
+		l.Snapshot()
+		l.compactions.Wait()
+	}
+	for i := 0; i < 200; i++ {
+		expected = append(expected, intElement{i})
+	}
+
+	l.readMu.Lock()
+	s := ShapeOf(l.history)
+	l.readMu.Unlock()
+	if s.Depth > 4 {
+		t.Fatalf("history should be compacted. got: %+v", s)
+	}
+	if got := Elements(AsSlice(l.Snapshot())); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("snapshot. got: %v, expected: %v", got, expected)
+	}
+}
+
+func Test_Log_Close(t *testing.T) {
+	l := NewLog().(*generationalLog)
+	l.SetCompactionPolicy(CompactionPolicy{MaxDepth: 2})
+	for i := 0; i < 10; i++ {
+		l.Append(NewElements(sequence(i, 100, 10)))
+		l.Snapshot()
+	}
+	l.Close()
+	for i := 0; i < 10; i++ {
+		l.Append(NewElements(sequence(i+100, 200, 10)))
+		l.Snapshot()
+	}
+
+	l.readMu.Lock()
+	compacting := l.compacting
+	l.readMu.Unlock()
+	if compacting {
+		t.Fatalf("a closed log should not start compactions")
+	}
+	expected := NewElements(sequence(0, 200, 1))
+	if got := Elements(AsSlice(l.Snapshot())); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("snapshot. got: %v, expected: %v", got, expected)
+	}
+}
@@ -0,0 +1,111 @@
+package tsl
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var (
+	// ErrCursorClosed is returned by the methods of a CursorContext after it has been closed.
+	ErrCursorClosed = errors.New("cursor is closed.")
+)
+
+// contextFillSize is the maximum number of elements that a CursorContext reads between
+// checks of its context.
+const contextFillSize = 256
+
+// A CursorContext is a cursor whose reads stop once its context is done. A CursorContext
+// must be closed once it is no longer needed.
+type CursorContext interface {
+	// Next answers the next element, or nil at the end of the range. Once the context is
+	// done, Next answers the error of the context.
+	Next() (Element, error)
+	// Fill fills buffer with at most len(buffer) elements, answering the number of elements
+	// filled. Once the context is done, Fill answers the error of the context, together with
+	// the number of elements filled before the context was found to be done.
+	Fill(buffer []Element) (int, error)
+	// Close releases the resources held by the cursor, including the buffered block of a
+	// segment, and answers the first error encountered by the underlying cursor, if any.
+	Close() error
+}
+
+// OpenContext opens a CursorContext over r that stops reading once ctx is done. The
+// context is checked before each call to Next and at least once for every 256 elements
+// that are filled, so a cancelled cursor stops advancing the merges it reads from.
+func OpenContext(ctx context.Context, r SortedRange) CursorContext {
+	return &contextCursor{
+		ctx:    ctx,
+		cursor: r.Open(),
+	}
+}
+
+// contextCursor is a CursorContext over an underlying cursor, which is released on Close.
+type contextCursor struct {
+	ctx    context.Context
+	cursor Cursor
+}
+
+// check answers an error if the cursor is closed or its context is done.
+func (c *contextCursor) check() error {
+	if c.cursor == nil {
+		return ErrCursorClosed
+	}
+	return c.ctx.Err()
+}
+
+func (c *contextCursor) Next() (Element, error) {
+	if err := c.check(); err != nil {
+		return nil, err
+	}
+	if e := c.cursor.Next(); e != nil {
+		return e, nil
+	}
+	return nil, cursorErr(c.cursor)
+}
+
+func (c *contextCursor) Fill(buffer []Element) (int, error) {
+	filled := 0
+	for filled < len(buffer) {
+		if err := c.check(); err != nil {
+			return filled, err
+		}
+		end := filled + contextFillSize
+		if end > len(buffer) {
+			end = len(buffer)
+		}
+		n := c.cursor.Fill(buffer[filled:end])
+		exhausted := filled+n < end
+		filled += n
+		if exhausted {
+			return filled, cursorErr(c.cursor)
+		}
+	}
+	return filled, nil
+}
+
+func (c *contextCursor) Close() error {
+	if c.cursor == nil {
+		return ErrCursorClosed
+	}
+	err := closeCursor(c.cursor)
+	c.cursor = nil
+	return err
+}
+
+// cursorErr answers the error encountered by a cursor that reports errors, such as a
+// cursor over a segment.
+func cursorErr(c Cursor) error {
+	if e, ok := c.(interface{ Err() error }); ok {
+		return e.Err()
+	}
+	return nil
+}
+
+// closeCursor closes a cursor that holds resources which can be released.
+func closeCursor(c Cursor) error {
+	if closer, ok := c.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
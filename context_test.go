@@ -0,0 +1,163 @@
+package tsl
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+)
+
+func Test_OpenContext(t *testing.T) {
+	r := Merge(newImmutableRange(NewElements(sequence(0, 1000, 2))), newImmutableRange(NewElements(sequence(1, 1000, 2))))
+	c := OpenContext(context.Background(), r)
+	got := Elements{}
+	for {
+		e, err := c.Next()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if e == nil {
+			break
+		}
+		got = append(got, e)
+	}
+	if expected := NewElements(sequence(0, 1000, 1)); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("next. got: %v, expected: %v", got, expected)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := c.Next(); err != ErrCursorClosed {
+		t.Fatalf("next after close. got: %v, expected: %v", err, ErrCursorClosed)
+	}
+	if err := c.Close(); err != ErrCursorClosed {
+		t.Fatalf("close after close. got: %v, expected: %v", err, ErrCursorClosed)
+	}
+}
+
+func Test_OpenContext_Cancel(t *testing.T) {
+	u := NewUnsortedRange()
+	u.Add(NewElements(sequence(0, 10000, 2)))
+	u.Add(NewElements(sequence(1, 10000, 2)))
+	r := u.Freeze()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := OpenContext(ctx, r)
+	buffer := make([]Element, 100)
+	if n, err := c.Fill(buffer); n != 100 || err != nil {
+		t.Fatalf("fill. got: %d, %v, expected: 100, nil", n, err)
+	}
+	cancel()
+	if e, err := c.Next(); e != nil || err != context.Canceled {
+		t.Fatalf("next after cancel. got: %v, %v, expected: nil, %v", e, err, context.Canceled)
+	}
+	if n, err := c.Fill(buffer); n != 0 || err != context.Canceled {
+		t.Fatalf("fill after cancel. got: %d, %v, expected: 0, %v", n, err, context.Canceled)
+	}
+	if s := Inspect(r); s.Merged > 1000 {
+		t.Fatalf("a cancelled cursor should not advance the merge. got: %v", s)
+	}
+	c.Close()
+}
+
+func Test_OpenContext_FillEnd(t *testing.T) {
+	r := newImmutableRange(NewElements(sequence(0, 1000, 1)))
+	c := OpenContext(context.Background(), r)
+	buffer := make([]Element, 2000)
+	n, err := c.Fill(buffer)
+	if n != 1000 || err != nil {
+		t.Fatalf("fill. got: %d, %v, expected: 1000, nil", n, err)
+	}
+	if !reflect.DeepEqual(Elements(buffer[0:n]), NewElements(sequence(0, 1000, 1))) {
+		t.Fatalf("fill. got: %v", buffer[0:n])
+	}
+}
+
+func Test_OpenContext_Segment(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := WriteSegment(buf, newImmutableRange(NewElements(sequence(0, 3000, 1))), intCodec{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data := buf.Bytes()
+	s, err := OpenSegment(bytes.NewReader(data), int64(len(data)), intCodec{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	c := OpenContext(context.Background(), Merge(s, newImmutableRange(NewElements([]int{5000}))))
+	if e, err := c.Next(); err != nil || e != (intElement{0}) {
+		t.Fatalf("next. got: %v, %v, expected: 0, nil", e, err)
+	}
+	disjoint := c.(*contextCursor).cursor.(*disjointCursor)
+	segment := disjoint.cursor.(*segmentCursor)
+	if err := c.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if segment.elements != nil || disjoint.cursor != nil {
+		t.Fatalf("close should release the buffered block")
+	}
+
+	data[segmentHeaderSize+3] ^= 0xff
+	s, _ = OpenSegment(bytes.NewReader(data), int64(len(data)), intCodec{})
+	c = OpenContext(context.Background(), s)
+	if e, err := c.Next(); e != nil || err != ErrBadSegment {
+		t.Fatalf("corrupt block. got: %v, %v, expected: nil, %v", e, err, ErrBadSegment)
+	}
+	if err := c.Close(); err != ErrBadSegment {
+		t.Fatalf("close. got: %v, expected: %v", err, ErrBadSegment)
+	}
+}
+
+func Test_OpenContext_CorruptMiddleSegment(t *testing.T) {
+	segments := []SortedRange{}
+	for i := 0; i < 3; i++ {
+		buf := &bytes.Buffer{}
+		if err := WriteSegment(buf, newImmutableRange(NewElements(sequence(i*100, (i+1)*100, 1))), intCodec{}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		data := buf.Bytes()
+		if i == 1 {
+			data[segmentHeaderSize+3] ^= 0xff
+		}
+		s, err := OpenSegment(bytes.NewReader(data), int64(len(data)), intCodec{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		segments = append(segments, s)
+	}
+	r := Merge(Merge(segments[0], segments[1]), segments[2])
+	if _, ok := r.(*disjointRanges); !ok {
+		t.Fatalf("the segments should be disjoint. got: %v", r)
+	}
+
+	c := OpenContext(context.Background(), r)
+	got := Elements{}
+	var err error
+	for {
+		var e Element
+		if e, err = c.Next(); e == nil || err != nil {
+			break
+		}
+		got = append(got, e)
+	}
+	if err != ErrBadSegment {
+		t.Fatalf("next. got: %v, expected: %v", err, ErrBadSegment)
+	}
+	if expected := NewElements(sequence(0, 100, 1)); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("elements before the corrupt segment. got: %v, expected: %v", got, expected)
+	}
+	if err := c.Close(); err != ErrBadSegment {
+		t.Fatalf("close. got: %v, expected: %v", err, ErrBadSegment)
+	}
+
+	for _, open := range []func(SortedRange) Cursor{SortedRange.Open, OpenReverse} {
+		cursor := open(r)
+		buffer := make([]Element, 300)
+		if n := cursor.Fill(buffer); n != 100 {
+			t.Fatalf("fill should stop at the corrupt segment. got: %d, expected: 100", n)
+		}
+		if err := cursorErr(cursor); err != ErrBadSegment {
+			t.Fatalf("fill. got: %v, expected: %v", err, ErrBadSegment)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package tsl
+
+import (
+	"testing"
+)
+
+func Test_Count(t *testing.T) {
+	u := NewUnsortedRange()
+	u.Add(NewElements(sequence(0, 100, 2)))
+	u.Add(NewElements(sequence(0, 100, 3)))
+	mergeable := u.Freeze()
+
+	kway := MergeAll(
+		newImmutableRange(NewElements(sequence(0, 100, 3))),
+		newImmutableRange(NewElements(sequence(1, 100, 3))),
+		newImmutableRange(NewElements(sequence(0, 100, 2))),
+	)
+
+	disjoint := Merge(newImmutableRange(NewElements(sequence(0, 50, 2))), newImmutableRange(NewElements(sequence(40, 100, 4))))
+
+	cases := []struct {
+		name  string
+		r     SortedRange
+		count int
+	}{
+		{"empty", EmptyRange, 0},
+		{"immutable", newImmutableRange(NewElements(sequence(0, 10, 1))), 10},
+		{"mergeable", mergeable, 67},
+		{"kway", kway, 84},
+		{"disjoint", disjoint, 37},
+		{"segment", writeSegment(t, newImmutableRange(NewElements(sequence(0, 3000, 1)))), 3000},
+		{"visible", Visible(Merge(newImmutableRange(live(1, 2, 3, 4)), newImmutableRange(tombstones(2, 5)))), 3},
+		{"not a counter", struct{ SortedRange }{Merge(newImmutableRange(NewElements(sequence(0, 10, 1))), newImmutableRange(NewElements(sequence(0, 10, 2))))}, 10},
+	}
+	for _, c := range cases {
+		if c.r.Limit() < c.count {
+			t.Fatalf("%s: limit must be an upper bound. got: %d, expected: >= %d", c.name, c.r.Limit(), c.count)
+		}
+		if got := Count(c.r); got != c.count {
+			t.Fatalf("%s: count. got: %d, expected: %d", c.name, got, c.count)
+		}
+		if got := len(AsSlice(c.r)); got != c.count {
+			t.Fatalf("%s: count after count. got: %d, expected: %d", c.name, got, c.count)
+		}
+		if err := checkSortedRangeInvariants(c.r); err != nil {
+			t.Fatalf("%s: got: %v. %v", c.name, c.r, err)
+		}
+	}
+}
+
+func Test_Count_DuringMerge(t *testing.T) {
+	u := NewUnsortedRange()
+	u.Add(NewElements(sequence(0, 100, 2)))
+	u.Add(NewElements(sequence(0, 100, 3)))
+	r := u.Freeze()
+
+	c := r.Open()
+	for i := 0; i < 10; i++ {
+		c.Next()
+	}
+	if got, expected := Count(r), 67; got != expected {
+		t.Fatalf("count. got: %d, expected: %d", got, expected)
+	}
+	got := 10
+	for e := c.Next(); e != nil; e = c.Next() {
+		got++
+	}
+	if expected := 67; got != expected {
+		t.Fatalf("a cursor opened before Count should see every element. got: %d, expected: %d", got, expected)
+	}
+}
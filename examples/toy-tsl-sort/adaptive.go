@@ -0,0 +1,116 @@
+package main
+
+import (
+	"sort"
+)
+
+const (
+	// minWindow is the smallest window the controller will choose, if its floor is smaller.
+	minWindow = 16
+	// safetyFactor is the ratio between the window and the largest recently observed lateness.
+	safetyFactor = 2
+	// horizonFactor is the ratio between the number of records for which lateness can be
+	// measured and the window.
+	horizonFactor = 4
+	// memory is the number of snapshots over which the largest lateness is remembered
+	// before the window is allowed to shrink.
+	memory = 8
+)
+
+// windowChange is a JSON encodable record of a change to the window.
+type windowChange struct {
+	Read     int  `json:"read"`              // the number of records read when the window changed
+	Window   int  `json:"window"`            // the new window
+	Lateness int  `json:"lateness"`          // the largest recently observed lateness
+	Spilled  bool `json:"spilled,omitempty"` // true if the change was caused by a spill
+}
+
+// controller adapts the window to the observed lateness of records, which is the number of
+// records that have been read since the first record that sorts after a newly read record.
+// Progressive output remains correctly ordered provided that lateness stays within the
+// window, so the controller grows the window as soon as lateness approaches it and shrinks
+// the window again once lateness has stayed low for a while.
+//
+// The window never shrinks below the floor, which is the initial window, so that input
+// which is already sorted, and so has no lateness, is not processed with a window so small
+// that the cost of each snapshot dominates.
+//
+// Lateness is measured with a monotonic stack of the prefix maxima of the records read
+// within the horizon. The first record that sorts after a new record is the first prefix
+// maximum that does, which can be found with a binary search of the stack.
+//
+// Lateness is measured in records because the window is a number of records. Lateness
+// measured in time is out of scope for the example, since it requires the time of each
+// record: tsl-sort --lateness, which is built on tsl.Watermark, sorts input whose lateness
+// is bounded in time.
+type controller struct {
+	floor  int        // the smallest window the controller will choose
+	maxima []*element // the prefix maxima read within the horizon, in input order
+	peaks  []int      // the largest lateness observed in each of the last memory snapshots
+	peak   int        // the largest lateness observed since the last snapshot
+}
+
+// observe records the lateness of a newly read record.
+func (c *controller) observe(e *element, window int) {
+	horizon := e.ordinal - horizonFactor*window
+	for len(c.maxima) > 0 && c.maxima[0].ordinal < horizon {
+		c.maxima = c.maxima[1:]
+	}
+
+	i := sort.Search(len(c.maxima), func(i int) bool {
+		return e.Less(c.maxima[i])
+	})
+	if i == len(c.maxima) {
+		c.maxima = append(c.maxima, e)
+		return
+	}
+
+	// Lateness beyond the horizon is underestimated, but such a record will also sort
+	// before the previous split, so snapshot grows the window when it spills the record.
+	if lateness := e.ordinal - c.maxima[i].ordinal; lateness > c.peak {
+		c.peak = lateness
+	}
+}
+
+// adjust answers the window to be used after a snapshot taken with the specified window,
+// together with the largest recently observed lateness. The window grows immediately to
+// accommodate the lateness observed since the last snapshot, and at least doubles if the
+// snapshot spilled, but shrinks by a quarter of its size per snapshot, no further than the
+// floor, only while it is more than twice as large as recent lateness requires.
+func (c *controller) adjust(window int, spilled bool) (int, int) {
+	c.peaks = append(c.peaks, c.peak)
+	if len(c.peaks) > memory {
+		c.peaks = c.peaks[1:]
+	}
+	c.peak = 0
+
+	lateness := 0
+	for _, p := range c.peaks {
+		if p > lateness {
+			lateness = p
+		}
+	}
+
+	target, next := safetyFactor*lateness, window
+	switch {
+	case spilled:
+		next = 2 * window
+		if target > next {
+			next = target
+		}
+	case target > window:
+		next = target
+	case target < window/2:
+		// shrinking only when the window is more than twice as large as it needs to be
+		// avoids small oscillations around the target
+		next = window - window/4
+	}
+	floor := c.floor
+	if floor < minWindow {
+		floor = minWindow
+	}
+	if next < floor {
+		next = floor
+	}
+	return next, lateness
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+)
+
+func Test_Controller_Observe(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		lines    []string
+		window   int
+		expected int
+	}{
+		{"sorted", []string{"01", "02", "03", "04", "05"}, 16, 0},
+		{"one late", []string{"01", "03", "04", "05", "02"}, 16, 3},
+		{"bursty", []string{"01", "03", "02", "05", "06", "07", "08", "04", "09"}, 16, 4},
+		{"duplicate", []string{"01", "02", "03", "02"}, 16, 1},
+		{"beyond the horizon", []string{"01", "02", "03", "04", "05", "06", "07", "08", "09", "10", "02"}, 1, 4},
+	} {
+		c := &controller{}
+		for i, line := range test.lines {
+			c.observe(&element{line: line, ordinal: i + 1}, test.window)
+		}
+		if c.peak != test.expected {
+			t.Fatalf("%s: lateness. got: %d, expected: %d", test.name, c.peak, test.expected)
+		}
+	}
+}
+
+func Test_Controller_Adjust(t *testing.T) {
+	// step is a snapshot with the largest lateness observed since the previous snapshot
+	// and the window that is expected to be used after it
+	type step struct {
+		peak    int
+		spilled bool
+		window  int
+	}
+	for _, test := range []struct {
+		name   string
+		floor  int
+		window int
+		steps  []step
+	}{
+		{"steady sorted input keeps the initial window", 1024, 1024, []step{
+			{0, false, 1024}, {0, false, 1024}, {0, false, 1024},
+		}},
+		{"grows to twice the lateness", 64, 64, []step{
+			{40, false, 80}, {100, false, 200}, {50, false, 200},
+		}},
+		{"bursty lateness is remembered", 16, 256, []step{
+			{100, false, 256}, {0, false, 256}, {0, false, 256}, {0, false, 256},
+			{0, false, 256}, {0, false, 256}, {0, false, 256}, {0, false, 256},
+			{0, false, 192}, {0, false, 144},
+		}},
+		{"shrinks no further than the floor", 100, 256, []step{
+			{0, false, 192}, {0, false, 144}, {0, false, 108}, {0, false, 100}, {0, false, 100},
+		}},
+		{"shrinks no further than the minimum", 0, 20, []step{
+			{0, false, 16}, {0, false, 16},
+		}},
+		{"does not oscillate around the target", 16, 256, []step{
+			{100, false, 256}, {70, false, 256},
+		}},
+		{"spilled input at least doubles", 16, 100, []step{
+			{10, true, 200}, {150, true, 400}, {300, true, 800},
+		}},
+		{"spilled input grows to twice the lateness", 16, 100, []step{
+			{500, true, 1000},
+		}},
+	} {
+		c := &controller{floor: test.floor}
+		window := test.window
+		for i, s := range test.steps {
+			c.peak = s.peak
+			window, _ = c.adjust(window, s.spilled)
+			if window != s.window {
+				t.Fatalf("%s: window after snapshot %d. got: %d, expected: %d", test.name, i, window, s.window)
+			}
+		}
+	}
+}
@@ -34,12 +34,13 @@ func (e *element) Less(o tsl.Element) bool {
 // statistics is a JSON encodable type which contains
 // observable statistics for the sort operation.
 type statistics struct {
-	Read            int      `json:"read"`
-	Duration        int64    `json:"duration"`
-	DurationSeconds float64  `json:"durationSeconds"`
-	Args            []string `json:"args"`
-	Window          int      `json:"window"`
-	SpillLimit      int      `json:"spillLimit"`
+	Read            int            `json:"read"`
+	Duration        int64          `json:"duration"`
+	DurationSeconds float64        `json:"durationSeconds"`
+	Args            []string       `json:"args"`
+	Window          int            `json:"window"`
+	WindowHistory   []windowChange `json:"windowHistory"`
+	SpillLimit      int            `json:"spillLimit"`
 }
 
 // process encapsulates the processing state of a tsl-sort process.
@@ -49,8 +50,10 @@ type process struct {
 	sorted      chan tsl.SortedRange // a channel used to accumulate sorted records
 	spills      chan tsl.SortedRange // a channel used to accumulate "old" records that need to be spilled
 	progressive bool                 // true if sorted output is to be written progressively
+	adaptive    bool                 // true if the window adapts to the observed lateness of records
+	controller  controller           // the controller that adapts the window
 	window      int                  // the number of records between splits
-	windowCount int                  // the total number of records since the last spill
+	windowCount int                  // the total number of records since the last snapshot
 	buffer      tsl.UnsortedRange    // a buffer for records as they are read
 	keep        tsl.SortedRange      // the younger part of the last split
 	split       *element             // record to be used for the next split
@@ -61,12 +64,7 @@ type process struct {
 // dump Writes a SortedRange to the process' Writer by iterating
 // over the specified range's cursor.
 func (p *process) dump(r tsl.SortedRange) {
-	c := r.Open()
-	for {
-		e := c.Next()
-		if e == nil {
-			break
-		}
+	for e := range tsl.All(r) {
 		p.output.WriteString(e.(*element).line + "\n")
 	}
 }
@@ -115,6 +113,7 @@ func (p *process) snapshot(current *element) {
 
 	write, hold := p.buffer.Freeze().Partition(p.split, tsl.LessOrder)
 
+	spilled := false
 	if p.prevsplit != nil {
 		// check that we don't have anything that sorts before the previous split.
 		var older tsl.SortedRange
@@ -123,8 +122,7 @@ func (p *process) snapshot(current *element) {
 			// we can't write this to the sorted channel, because it violate
 			// the invariant about never writing anything into p.sorted that
 			// is older than p.prevsplit
-			p.window = 2 * p.window
-			p.windowCount = 0
+			spilled = true
 			p.spills <- older
 			write = newer // only write the newer portion of the write partition
 		}
@@ -132,6 +130,18 @@ func (p *process) snapshot(current *element) {
 
 	p.sorted <- tsl.Merge(p.keep, write)
 
+	window, lateness := p.window, 0
+	if p.adaptive {
+		window, lateness = p.controller.adjust(p.window, spilled)
+	} else if spilled {
+		window = 2 * p.window
+	}
+	if window != p.window {
+		p.stats.WindowHistory = append(p.stats.WindowHistory, windowChange{Read: p.stats.Read, Window: window, Lateness: lateness, Spilled: spilled})
+		p.window = window
+	}
+	p.windowCount = 0
+
 	p.buffer, p.keep, p.prevsplit, p.split = tsl.NewUnsortedRange(), hold, p.split, current
 }
 
@@ -144,6 +154,7 @@ func (p *process) run() (int, int) {
 
 	p.buffer = tsl.NewUnsortedRange()
 	p.keep = tsl.EmptyRange
+	p.controller.floor = p.window
 
 	p.sorted = make(chan tsl.SortedRange)
 	p.spills = make(chan tsl.SortedRange)
@@ -170,7 +181,10 @@ func (p *process) run() (int, int) {
 				p.split = current
 			}
 			p.buffer.Add([]tsl.Element{current})
-			if p.windowCount%p.window == 0 {
+			if p.adaptive {
+				p.controller.observe(current, p.window)
+			}
+			if p.windowCount >= p.window {
 				p.snapshot(current)
 			}
 		}
@@ -189,7 +203,15 @@ func (p *process) run() (int, int) {
 	p.stats.Window = p.window
 	p.stats.SpillLimit = spill.Limit()
 
-	return spill.Limit(), p.window
+	// the window only grows unless it is adaptive, in which case the largest window is
+	// the best guess at an initial window that avoids spills
+	largest := p.window
+	for _, change := range p.stats.WindowHistory {
+		if change.Window > largest {
+			largest = change.Window
+		}
+	}
+	return spill.Limit(), largest
 }
 
 func main() {
@@ -205,16 +227,17 @@ func main() {
 	flag.BoolVar(&dumpStatistics, "statistics", false, "Dump a statistics record to stdout on exit.")
 	flag.StringVar(&comment, "comment", "", "Arbitrary text to be logged as an argument.")
 	flag.BoolVar(&process.progressive, "progressive", false, "Progressively write sorted output with finite probability that data will be written out of sort order.")
+	flag.BoolVar(&process.adaptive, "adaptive", true, "Adapt the window to the observed lateness of records. --window then sets the initial, and smallest, window.")
 	flag.Parse()
 
-	spillLimit, finalWindow := process.run()
+	spillLimit, largestWindow := process.run()
 
 	if dumpStatistics {
 		json.NewEncoder(os.Stderr).Encode(process.stats)
 	}
 
 	if process.progressive && spillLimit > 0 {
-		fmt.Fprintf(os.Stderr, "last %d records written out of order. increase --window to at least %d\n", spillLimit, finalWindow)
+		fmt.Fprintf(os.Stderr, "last %d records written out of order. increase --window to at least %d\n", spillLimit, largestWindow)
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,108 @@
+package tsl
+
+import (
+	"reflect"
+	"testing"
+)
+
+// checkGet checks that Get finds each element of r and none of the misses.
+func checkGet(t *testing.T, r SortedRange, misses []int) {
+	if _, ok := r.(Getter); !ok {
+		t.Fatalf("range should be a getter. got: %T", r)
+	}
+	for _, e := range AsSlice(r) {
+		got, ok := Get(r, e)
+		if !ok || !reflect.DeepEqual(got, e) {
+			t.Fatalf("Get(%v) over %v. got: %v, %v, expected: %v, true", e, r, got, ok, e)
+		}
+	}
+	for _, k := range misses {
+		if got, ok := Get(r, intElement{k}); ok {
+			t.Fatalf("Get(%v) over %v. got: %v, %v, expected: nil, false", k, r, got, ok)
+		}
+	}
+}
+
+func Test_Get_Immutable(t *testing.T) {
+	checkGet(t, newImmutableRange(NewElements(sequence(0, 100, 2))), []int{-1, 1, 51, 99, 100})
+	checkGet(t, EmptyRange, []int{0})
+}
+
+func Test_Get_Disjoint(t *testing.T) {
+	r := MergeAll(
+		newImmutableRange(NewElements(sequence(60, 100, 3))),
+		newImmutableRange(NewElements(sequence(0, 20, 3))),
+		newImmutableRange(NewElements(sequence(30, 50, 3))),
+	)
+	checkGet(t, r, []int{-1, 1, 20, 25, 50, 55, 61, 100})
+}
+
+func Test_Get_Segment(t *testing.T) {
+	s := writeSegment(t, newImmutableRange(NewElements(sequence(0, 5000, 2))))
+	checkGet(t, s, []int{-1, 1, 2047, 2049, 4999, 5000})
+	_, p := s.Partition(intElement{3000}, LessOrder)
+	checkGet(t, p, []int{0, 2998, 3001, 5000})
+}
+
+// Test_Get_Unmerged checks that Get combines the arms of an unfinished merge with the
+// resolver in the same way as the merge itself.
+func Test_Get_Unmerged(t *testing.T) {
+	a := newImmutableRange(keyed(1, 1, 2, 2, 3, 3))
+	b := newImmutableRange(keyed(2, 20, 3, 30, 4, 40))
+	c := newImmutableRange(keyed(3, 300, 4, 400, 5, 500))
+
+	build := []func() SortedRange{
+		func() SortedRange { return Merge(a, b) },
+		func() SortedRange { return MergeWith(a, b, sum) },
+		func() SortedRange { return MergeWith(a, b, KeepFirst) },
+		func() SortedRange { return MergeAllWith(sum, a, b, c) },
+	}
+	for _, f := range build {
+		r := f()
+		for _, e := range AsSlice(f()) {
+			if got, ok := Get(r, e); !ok || got != e {
+				t.Fatalf("Get(%v) before merge over %v. got: %v, %v, expected: %v, true", e, r, got, ok, e)
+			}
+		}
+		checkGet(t, r, nil)
+		if got, ok := Get(r, keyed(6, 0)[0]); ok {
+			t.Fatalf("Get(6). got: %v, %v, expected: nil, false", got, ok)
+		}
+	}
+}
+
+func Test_Get_PartiallyMerged(t *testing.T) {
+	u := NewUnsortedRange()
+	u.Add(NewElements(sequence(0, 100, 2)))
+	u.Add(NewElements(sequence(1, 100, 2)))
+	r := u.Freeze()
+
+	c := r.Open()
+	for i := 0; i < 10; i++ {
+		c.Next()
+	}
+	checkGet(t, r, []int{-1, 100})
+}
+
+func Test_Get_Visible(t *testing.T) {
+	r := Merge(newImmutableRange(live(1, 2, 3)), newImmutableRange(tombstones(2)))
+	if got, ok := Get(r, live(2)[0]); !ok || !isTombstone(got) {
+		t.Fatalf("Get should answer tombstones. got: %v, %v", got, ok)
+	}
+	if got, ok := Get(Visible(r), live(2)[0]); ok {
+		t.Fatalf("Get should hide tombstones of visible ranges. got: %v, %v", got, ok)
+	}
+	if got, ok := Get(Visible(r), live(3)[0]); !ok || got != live(3)[0] {
+		t.Fatalf("Get(3). got: %v, %v, expected: %v, true", got, ok, live(3)[0])
+	}
+}
+
+func Test_Get_NotGetter(t *testing.T) {
+	r := struct{ SortedRange }{newImmutableRange(NewElements(sequence(0, 10, 2)))}
+	if got, ok := Get(r, intElement{4}); !ok || got != (intElement{4}) {
+		t.Fatalf("Get(4). got: %v, %v, expected: 4, true", got, ok)
+	}
+	if got, ok := Get(r, intElement{5}); ok {
+		t.Fatalf("Get(5). got: %v, %v, expected: nil, false", got, ok)
+	}
+}
@@ -44,6 +44,24 @@ func (r *immutableRange) Open() Cursor {
 	}
 }
 
+// Count answers the number of elements in the range, which is known exactly.
+func (r *immutableRange) Count() int {
+	return len(r.elements)
+}
+
+// Get uses a binary search to find the element equal to key.
+func (r *immutableRange) Get(key Element) (Element, bool) {
+	return get(r.elements, key)
+}
+
+// OpenReverse opens a cursor that iterates backwards over the immutable range.
+func (r *immutableRange) OpenReverse() Cursor {
+	return &reverseCursor{
+		next:     len(r.elements),
+		elements: r.elements,
+	}
+}
+
 // Partition use a binary search to partition the receiver into a pair disjoint
 // SortedRanges such that o(i, e) is true for each element i of the first member of the returned pair
 // and o(i, e) is false for each element i of the second member of the returned pair.
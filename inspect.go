@@ -0,0 +1,140 @@
+package tsl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Stats describes a range within the tree of ranges from which a SortedRange is composed.
+// Stats never include the values of elements.
+type Stats struct {
+	// Type is the name of the type of the range, such as "immutableRange" or "mergeableRange".
+	Type string
+	// Depth is the distance of the range from the root of the tree, which has a depth of 0.
+	Depth int
+	// Limit is the Limit of the range. The Limit of a visible view is reported as the Limit
+	// of the range it views, since a view that has not been read may need to search the
+	// range it views to find its own Limit.
+	Limit int
+	// Merge is true if the range is a merge of other ranges.
+	Merge bool
+	// Copied is the number of elements that a merge has copied into its merged slice.
+	Copied int
+	// Merged is the number of elements of the merged slice that are final. Once a merge
+	// is complete, Merged is the number of elements in the range.
+	Merged int
+	// Len and Cap are the length and capacity of the slice that holds the elements of the
+	// range, if any. A slice may be shared by several ranges, for example, by the partitions
+	// of an immutableRange.
+	Len int
+	Cap int
+	// Children describe the ranges from which the range is composed.
+	Children []Stats
+
+	view bool // true if the range is a visible view of its only child
+}
+
+// Inspect answers the Stats of r and of the ranges from which it is composed. Inspect
+// neither advances merges nor reads elements.
+func Inspect(r SortedRange) Stats {
+	return inspect(r, 0)
+}
+
+func inspect(r SortedRange, depth int) Stats {
+	s := Stats{
+		Type:  strings.TrimPrefix(fmt.Sprintf("%T", r), "*tsl."),
+		Depth: depth,
+	}
+
+	var children []SortedRange
+	switch t := r.(type) {
+	case *immutableRange:
+		s.Len, s.Cap = len(t.elements), cap(t.elements)
+	case *mergeableRange:
+		t.mu.RLock()
+		s.Merge, s.Copied, s.Merged, s.Len, s.Cap = true, t.mx, t.nx, len(t.elements), cap(t.elements)
+		t.mu.RUnlock()
+		children = t.children()
+	case *kwayRange:
+		t.mu.RLock()
+		s.Merge, s.Copied, s.Merged, s.Len, s.Cap = true, t.nx, t.nx, len(t.elements), cap(t.elements)
+		t.mu.RUnlock()
+		children = t.children()
+	case *visibleRange:
+		s.view, s.Limit = true, t.underlying.Limit()
+		children = []SortedRange{t.underlying}
+	case node:
+		children = t.children()
+	}
+
+	if !s.view {
+		s.Limit = r.Limit()
+	}
+	for _, c := range children {
+		s.Children = append(s.Children, inspect(c, depth+1))
+	}
+	return s
+}
+
+// Shape answers the shape of the tree described by the receiver. Visible views are
+// transparent, so the shape of a visible view is the shape of the range it views.
+func (s Stats) Shape() Shape {
+	if s.view {
+		return s.Children[0].Shape()
+	}
+	if len(s.Children) == 0 {
+		return leaf
+	}
+	shape := Shape{Nodes: 1, MaxFanOut: len(s.Children)}
+	for _, c := range s.Children {
+		shape.add(c.Shape())
+	}
+	return shape
+}
+
+// label answers a description of the range, without its children.
+func (s Stats) label() string {
+	label := fmt.Sprintf("%s limit=%d", s.Type, s.Limit)
+	if s.Merge {
+		label += fmt.Sprintf(" copied=%d merged=%d", s.Copied, s.Merged)
+	}
+	if s.Cap > 0 {
+		label += fmt.Sprintf(" len=%d cap=%d", s.Len, s.Cap)
+	}
+	return label
+}
+
+// String renders the tree as indented text, one range per line.
+func (s Stats) String() string {
+	b := &strings.Builder{}
+	s.text(b)
+	return b.String()
+}
+
+func (s Stats) text(b *strings.Builder) {
+	fmt.Fprintf(b, "%s%s\n", strings.Repeat("  ", s.Depth), s.label())
+	for _, c := range s.Children {
+		c.text(b)
+	}
+}
+
+// DOT renders the tree as a Graphviz digraph.
+func (s Stats) DOT() string {
+	b := &strings.Builder{}
+	b.WriteString("digraph SortedRange {\n\tnode [shape=box];\n")
+	next := 0
+	s.dot(b, &next)
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// dot writes the node for the range and its children, answering the id of the node.
+func (s Stats) dot(b *strings.Builder, next *int) int {
+	id := *next
+	*next++
+	fmt.Fprintf(b, "\tn%d [label=%q];\n", id, strings.Replace(s.label(), " ", "\n", -1))
+	for _, c := range s.Children {
+		fmt.Fprintf(b, "\tn%d -> n%d;\n", id, c.dot(b, next))
+	}
+	return id
+}
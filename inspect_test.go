@@ -0,0 +1,63 @@
+package tsl
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_Inspect(t *testing.T) {
+	a := newImmutableRange(NewElements([]int{0, 1, 2, 4, 6}))
+	b := newImmutableRange(NewElements([]int{3, 5, 7}))
+	r := Visible(Merge(a, b))
+
+	c := r.Open()
+	c.Next()
+	c.Next()
+	c.Next()
+	c.Next()
+
+	expected := strings.Join([]string{
+		"visibleRange limit=8",
+		"  disjointRanges limit=8",
+		"    immutableRange limit=3 len=3 cap=5",
+		"    mergeableRange limit=4 copied=2 merged=1 len=4 cap=4",
+		"      immutableRange limit=2 len=2 cap=2",
+		"      immutableRange limit=2 len=2 cap=3",
+		"    immutableRange limit=1 len=1 cap=1",
+		"",
+	}, "\n")
+	stats := Inspect(r)
+	if got := stats.String(); got != expected {
+		t.Fatalf("text. got:\n%s\nexpected:\n%s", got, expected)
+	}
+	if stats.Children[0].Children[1].Depth != 2 {
+		t.Fatalf("depth. got: %d, expected: 2", stats.Children[0].Children[1].Depth)
+	}
+
+	dot := stats.DOT()
+	for _, s := range []string{"digraph SortedRange {", "n0 [label=\"visibleRange\\nlimit=8\"];", "n1 -> n3;", "n3 -> n5;"} {
+		if !strings.Contains(dot, s) {
+			t.Fatalf("dot. got:\n%s\nexpected to contain: %s", dot, s)
+		}
+	}
+
+	AsSlice(r)
+	if got, expected := Inspect(r).Children[0].Children[1].label(), "mergeableRange limit=4 copied=4 merged=4 len=4 cap=4"; got != expected {
+		t.Fatalf("complete merge. got: %s, expected: %s", got, expected)
+	}
+}
+
+func Test_Inspect_Shape(t *testing.T) {
+	a := newImmutableRange(tombstones(0, 2, 4))
+	b := newImmutableRange(live(1, 3, 5))
+	m := MergeWith(a, b, LastWins)
+	v := Visible(m).(*visibleRange)
+
+	stats := Inspect(v)
+	if got, expected := stats.Shape(), ShapeOf(m); got != expected {
+		t.Fatalf("shape. got: %+v, expected: %+v", got, expected)
+	}
+	if got := Inspect(m).String(); !strings.Contains(got, "mergeableRange limit=4 copied=0 ") {
+		t.Fatalf("inspect should not search the view for its first visible element. got:\n%s", got)
+	}
+}
@@ -56,7 +56,7 @@ func checkSortedRangeInvariants(r SortedRange) error {
 		}
 		for i := 1; i < len(slice); i++ {
 			if !LessOrder(slice[i-1], slice[i]) {
-				fmt.Errorf("adjacent elements must always satisfy LessOrder. got: false. expected: true. i: %d", i)
+				return fmt.Errorf("adjacent elements must always satisfy LessOrder. got: false. expected: true. i: %d", i)
 			}
 		}
 		if len(slice) > 0 {
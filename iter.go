@@ -0,0 +1,61 @@
+package tsl
+
+import (
+	"iter"
+)
+
+// All answers an iterator over the elements of r in ascending order. Each iteration opens
+// a new cursor and reads no further than the loop does, so a merge within r is only advanced
+// as far as the elements that are actually read. The cursor is closed when the iteration
+// ends, even if the loop ends early.
+//
+// An error encountered by the cursor, such as a corrupt block of a segment, ends the iteration
+// as if r had no more elements. Callers that need to detect such errors should read r with a
+// cursor opened by OpenContext, whose Next and Fill answer them.
+func All(r SortedRange) iter.Seq[Element] {
+	return func(yield func(Element) bool) {
+		c := r.Open()
+		defer closeCursor(c)
+		for e := c.Next(); e != nil; e = c.Next() {
+			if !yield(e) {
+				return
+			}
+		}
+	}
+}
+
+// Backward answers an iterator over the elements of r in descending order. Like All, it
+// closes its cursor when the iteration ends and ends the iteration at the first error.
+func Backward(r SortedRange) iter.Seq[Element] {
+	return func(yield func(Element) bool) {
+		c := OpenReverse(r)
+		defer closeCursor(c)
+		for e := c.Next(); e != nil; e = c.Next() {
+			if !yield(e) {
+				return
+			}
+		}
+	}
+}
+
+// Chunks answers an iterator over the elements of r in ascending order, in slices of
+// n elements, except that the last slice may be shorter. Each slice is filled with a
+// single call to Cursor.Fill and is not reused by the iterator, so it may be retained.
+// Like All, it closes its cursor when the iteration ends and ends the iteration at the
+// first error. Chunks panics if n is not positive.
+func Chunks(r SortedRange, n int) iter.Seq[[]Element] {
+	if n <= 0 {
+		panic("tsl: Chunks requires a positive chunk size")
+	}
+	return func(yield func([]Element) bool) {
+		c := r.Open()
+		defer closeCursor(c)
+		for {
+			chunk := make([]Element, n)
+			filled := c.Fill(chunk)
+			if filled == 0 || !yield(chunk[0:filled]) || filled < n {
+				return
+			}
+		}
+	}
+}
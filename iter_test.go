@@ -0,0 +1,101 @@
+package tsl
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func Test_All(t *testing.T) {
+	r := Merge(newImmutableRange(NewElements(sequence(0, 100, 2))), newImmutableRange(NewElements(sequence(1, 100, 2))))
+	got := Elements(slices.Collect(All(r)))
+	if expected := NewElements(sequence(0, 100, 1)); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("all. got: %v, expected: %v", got, expected)
+	}
+	got = Elements(slices.Collect(Backward(r)))
+	if expected := reversed(NewElements(sequence(0, 100, 1))); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("backward. got: %v, expected: %v", got, expected)
+	}
+}
+
+func Test_All_Lazy(t *testing.T) {
+	// the odd elements before the insertion window are merged when the range is frozen
+	u := NewUnsortedRange()
+	u.Add(NewElements(sequence(0, 10000, 2)))
+	u.Add(NewElements(sequence(1, 10000, 2)))
+	r := u.Freeze()
+	if _, ok := r.(*mergeableRange); !ok {
+		t.Fatalf("expected a merge. got: %v", Inspect(r))
+	}
+
+	got := Elements{}
+	for e := range All(r) {
+		if e.(intElement).value >= 10 {
+			break
+		}
+		got = append(got, e)
+	}
+	if expected := NewElements(sequence(0, 10, 1)); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("all. got: %v, expected: %v", got, expected)
+	}
+	if s := Inspect(r); s.Copied > 12 {
+		t.Fatalf("the merge should only advance as far as the loop reads. got: %v", s)
+	}
+}
+
+func Test_Chunks(t *testing.T) {
+	r := newImmutableRange(NewElements(sequence(0, 10, 1)))
+	got := slices.Collect(Chunks(r, 4))
+	expected := [][]Element{NewElements([]int{0, 1, 2, 3}), NewElements([]int{4, 5, 6, 7}), NewElements([]int{8, 9})}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("chunks. got: %v, expected: %v", got, expected)
+	}
+
+	got = slices.Collect(Chunks(newImmutableRange(NewElements(sequence(0, 8, 1))), 4))
+	if len(got) != 2 {
+		t.Fatalf("chunks of an exact multiple. got: %v", got)
+	}
+	if got := slices.Collect(Chunks(EmptyRange, 4)); len(got) != 0 {
+		t.Fatalf("chunks of an empty range. got: %v", got)
+	}
+}
+
+// closingRange is a SortedRange whose cursors count the number of times they are closed.
+type closingRange struct {
+	SortedRange
+	closed *int
+}
+
+func (r closingRange) Open() Cursor {
+	return &closingCursor{Cursor: r.SortedRange.Open(), closed: r.closed}
+}
+
+func (r closingRange) OpenReverse() Cursor {
+	return &closingCursor{Cursor: OpenReverse(r.SortedRange), closed: r.closed}
+}
+
+type closingCursor struct {
+	Cursor
+	closed *int
+}
+
+func (c *closingCursor) Close() error {
+	*c.closed++
+	return nil
+}
+
+func Test_All_Closes(t *testing.T) {
+	closed := 0
+	r := closingRange{SortedRange: newImmutableRange(NewElements(sequence(0, 10, 1))), closed: &closed}
+	for range All(r) {
+		break
+	}
+	for range Backward(r) {
+	}
+	for range Chunks(r, 4) {
+		break
+	}
+	if closed != 3 {
+		t.Fatalf("closed cursors. got: %d, expected: 3", closed)
+	}
+}
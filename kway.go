@@ -0,0 +1,333 @@
+package tsl
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// kwayBatchSize is the number of elements merged while holding the write lock when
+// a k-way merge is completed in the background.
+const kwayBatchSize = 4096
+
+// kwayRange represents a possibly incomplete merge of any number of
+// SortedRanges. The arms of the merge are held in a heap ordered by the
+// next element of each arm so that each step of the merge costs
+// O(log(k)) comparisons, rather than the O(k) comparisons of a tree of
+// two-way merges.
+//
+// Like a mergeableRange, a kwayRange is advanced by its cursors as they reach
+// the end of the merged part of the range and becomes immutable once the merge
+// is complete.
+type kwayRange struct {
+	immutableRange
+	mu   sync.RWMutex
+	arms []SortedRange // the arms of the merge, later arms win
+	heap kwayHeap      // the arms that are not yet exhausted
+	nx   int           // number of merged elements
+	// combines equal elements from different arms, in the order of the arms
+	resolve Resolver
+}
+
+// kwayArm is a peekable cursor into one arm of a k-way merge.
+type kwayArm struct {
+	cursor *mergeCursor
+	index  int // the position of the arm in the list of arms
+}
+
+// kwayHeap is a heap of arms ordered by their next element. If two arms have
+// equal next elements, the earlier arm sorts first.
+type kwayHeap []*kwayArm
+
+func (h kwayHeap) Len() int {
+	return len(h)
+}
+
+func (h kwayHeap) Less(i, j int) bool {
+	a, b := h[i].cursor.peek(), h[j].cursor.peek()
+	if a.Less(b) {
+		return true
+	} else if b.Less(a) {
+		return false
+	}
+	return h[i].index < h[j].index
+}
+
+func (h kwayHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *kwayHeap) Push(x interface{}) {
+	*h = append(*h, x.(*kwayArm))
+}
+
+func (h *kwayHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[0 : len(old)-1]
+	return x
+}
+
+// skip consumes the next element of the arm at the top of the heap.
+func (h *kwayHeap) skip() Element {
+	e := (*h)[0].cursor.next()
+	if (*h)[0].cursor.peek() == nil {
+		heap.Pop(h)
+	} else {
+		heap.Fix(h, 0)
+	}
+	return e
+}
+
+func newKwayRange(arms []SortedRange, resolve Resolver) *kwayRange {
+	first, last := arms[0].First(), arms[0].Last()
+	limit := 0
+	h := make(kwayHeap, 0, len(arms))
+	for i, a := range arms {
+		if !first.Less(a.First()) {
+			first = a.First()
+		}
+		if !a.Last().Less(last) {
+			last = a.Last()
+		}
+		limit += a.Limit()
+		h = append(h, &kwayArm{
+			cursor: &mergeCursor{underlying: a.Open()},
+			index:  i,
+		})
+	}
+	heap.Init(&h)
+	return &kwayRange{
+		immutableRange: immutableRange{
+			basicRange: basicRange{
+				first:    first,
+				last:     last,
+				elements: make([]Element, limit),
+			},
+		},
+		arms:    arms,
+		heap:    h,
+		resolve: resolve,
+	}
+}
+
+// mergeOne copies the next element of the merge into the merged slice, combining
+// it with any equal elements from later arms. Must be called while holding the write lock.
+func (r *kwayRange) mergeOne() {
+	if r.arms == nil {
+		return
+	}
+	if len(r.heap) > 0 {
+		e := r.heap.skip()
+		for len(r.heap) > 0 && !e.Less(r.heap[0].cursor.peek()) {
+			e = r.resolve.apply(e, r.heap.skip())
+		}
+		r.elements[r.nx] = e
+		r.nx++
+	}
+	if len(r.heap) == 0 {
+		r.arms = nil
+		r.heap = nil
+		r.elements = r.elements[0:r.nx]
+	}
+}
+
+// complete completes the merge in batches of kwayBatchSize elements, releasing the write
+// lock between batches so that cursors can read the merged part of the range meanwhile.
+func (r *kwayRange) complete() {
+	for {
+		r.mu.Lock()
+		for i := 0; i < kwayBatchSize && r.arms != nil; i++ {
+			r.mergeOne()
+		}
+		complete := r.arms == nil
+		r.mu.Unlock()
+		if complete {
+			return
+		}
+	}
+}
+
+// Open opens a cursor over the merged slice if the merge is complete, or a cursor
+// that advances the merge otherwise.
+func (r *kwayRange) Open() Cursor {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.arms == nil {
+		return r.immutableRange.Open()
+	}
+	return &mergeableCursor{
+		basicCursor: basicCursor{
+			next:     0,
+			elements: r.elements,
+		},
+		advance: r.mergeOne,
+		mu:      &r.mu,
+		nx:      &r.nx,
+	}
+}
+
+// Count completes the merge, if it is not already complete, and answers the number
+// of merged elements.
+func (r *kwayRange) Count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for r.arms != nil {
+		r.mergeOne()
+	}
+	return len(r.elements)
+}
+
+// Get searches the merged part of the range if key sorts within it. Otherwise, it
+// looks up key in each arm and combines the results in the order of the arms.
+func (r *kwayRange) Get(key Element) (Element, bool) {
+	r.mu.RLock()
+	arms := r.arms
+	merged := r.elements[0:r.nx]
+	r.mu.RUnlock()
+
+	if arms == nil || (len(merged) > 0 && !merged[len(merged)-1].Less(key)) {
+		return get(merged, key)
+	}
+
+	var found Element
+	for _, a := range arms {
+		if e, ok := Get(a, key); !ok {
+			continue
+		} else if found == nil {
+			found = e
+		} else {
+			found = r.resolve.apply(found, e)
+		}
+	}
+	return found, found != nil
+}
+
+// children answers the arms of the merge, or nil if the merge is complete.
+func (r *kwayRange) children() []SortedRange {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.arms
+}
+
+// immutable completes the merge and answers an immutableRange over the merged slice.
+func (r *kwayRange) immutable() SortedRange {
+	r.Count()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return newImmutableRange(r.elements)
+}
+
+// OpenReverse opens a cursor over the merged slice if the merge is complete, or a
+// cursor that merges the arms from their last elements otherwise.
+func (r *kwayRange) OpenReverse() Cursor {
+	r.mu.RLock()
+	arms := r.arms
+	r.mu.RUnlock()
+
+	if arms == nil {
+		return r.immutableRange.OpenReverse()
+	}
+	return newReverseMergeCursor(arms, r.resolve)
+}
+
+func (r *kwayRange) Limit() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.elements)
+}
+
+// Partition partitions the merged slice if the merge is complete or each of the
+// arms of the merge otherwise.
+func (r *kwayRange) Partition(e Element, o Order) (SortedRange, SortedRange) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.arms == nil {
+		return r.immutableRange.Partition(e, o)
+	}
+	older := make([]SortedRange, len(r.arms))
+	newer := make([]SortedRange, len(r.arms))
+	for i, a := range r.arms {
+		older[i], newer[i] = a.Partition(e, o)
+	}
+	return mergeAll(older, r.resolve), mergeAll(newer, r.resolve)
+}
+
+func (r *kwayRange) String() string {
+	return fmt.Sprintf("kwayRange{first: %v, last: %v, arms: %d}", r.first, r.last, len(r.arms))
+}
+
+// mergeAll groups the non-empty ranges into clusters of overlapping ranges. Clusters
+// that contain a single range are concatenated without being merged. Clusters of two
+// ranges are merged with merge and larger clusters are merged with a kwayRange.
+func mergeAll(ranges []SortedRange, resolve Resolver) SortedRange {
+	nonEmpty := make([]SortedRange, 0, len(ranges))
+	for _, r := range ranges {
+		if r.Limit() > 0 {
+			nonEmpty = append(nonEmpty, r)
+		}
+	}
+	switch len(nonEmpty) {
+	case 0:
+		return EmptyRange
+	case 1:
+		return nonEmpty[0]
+	case 2:
+		return merge(nonEmpty[0], nonEmpty[1], resolve)
+	}
+
+	// order the ranges by their first element, keeping track of their original
+	// position so that the later of two equal elements can be identified.
+	order := make([]int, len(nonEmpty))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return nonEmpty[order[i]].First().Less(nonEmpty[order[j]].First())
+	})
+
+	segments := []SortedRange{}
+	cluster := []int{}
+	var last Element
+	flush := func() {
+		sort.Ints(cluster)
+		arms := make([]SortedRange, len(cluster))
+		for i, c := range cluster {
+			arms[i] = nonEmpty[c]
+		}
+		switch len(arms) {
+		case 1:
+			segments = append(segments, arms[0])
+		case 2:
+			segments = append(segments, merge(arms[0], arms[1], resolve))
+		default:
+			segments = append(segments, newKwayRange(arms, resolve))
+		}
+		cluster = cluster[0:0]
+	}
+	for _, i := range order {
+		r := nonEmpty[i]
+		if len(cluster) > 0 && last.Less(r.First()) {
+			flush()
+		}
+		if len(cluster) == 0 || last.Less(r.Last()) {
+			last = r.Last()
+		}
+		cluster = append(cluster, i)
+	}
+	flush()
+
+	if len(segments) == 1 {
+		return segments[0]
+	}
+	return &disjointRanges{
+		first:    segments[0].First(),
+		last:     segments[len(segments)-1].Last(),
+		segments: flatten(segments),
+	}
+}
@@ -0,0 +1,91 @@
+package tsl
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func Test_MergeAll_Empty(t *testing.T) {
+	got := MergeAll()
+	if got != EmptyRange {
+		t.Fatalf("MergeAll() -> yield empty. got: %v, expected: %v", got, EmptyRange)
+	}
+	got = MergeAll(EmptyRange, EmptyRange, EmptyRange)
+	if got != EmptyRange {
+		t.Fatalf("MergeAll(empty, empty, empty) -> yield empty. got: %v, expected: %v", got, EmptyRange)
+	}
+}
+
+func Test_MergeAll_Disjoint(t *testing.T) {
+	a := newImmutableRange(NewElements([]int{6, 7}))
+	b := newImmutableRange(NewElements([]int{0, 1}))
+	c := newImmutableRange(NewElements([]int{3, 4}))
+	got := MergeAll(a, b, c)
+
+	d, ok := got.(*disjointRanges)
+	if !ok {
+		t.Fatalf("disjoint inputs should be concatenated. got: %v", got)
+	}
+	expectedSegments := []SortedRange{b, c, a}
+	if !reflect.DeepEqual(d.segments, expectedSegments) {
+		t.Fatalf("segments. got: %v, expected: %v", d.segments, expectedSegments)
+	}
+	expected := NewElements([]int{0, 1, 3, 4, 6, 7})
+	if !reflect.DeepEqual(Elements(AsSlice(got)), expected) {
+		t.Fatalf("MergeAll. got: %v, expected: %v", AsSlice(got), expected)
+	}
+	if err := checkSortedRangeInvariants(got); err != nil {
+		t.Fatalf("got: %v. %v", got, err)
+	}
+}
+
+func Test_MergeAll_LaterWins(t *testing.T) {
+	a := newImmutableRange(Elements{keyedElement{1, 0}, keyedElement{2, 0}, keyedElement{3, 0}})
+	b := newImmutableRange(Elements{keyedElement{2, 1}, keyedElement{4, 1}})
+	c := newImmutableRange(Elements{keyedElement{1, 2}, keyedElement{2, 2}})
+	d := newImmutableRange(Elements{keyedElement{0, 3}, keyedElement{3, 3}})
+	got := MergeAll(a, b, c, d)
+
+	if _, ok := got.(*kwayRange); !ok {
+		t.Fatalf("overlapping inputs should be merged by a kwayRange. got: %v", got)
+	}
+	expected := Elements{keyedElement{0, 3}, keyedElement{1, 2}, keyedElement{2, 2}, keyedElement{3, 3}, keyedElement{4, 1}}
+	if !reflect.DeepEqual(Elements(AsSlice(got)), expected) {
+		t.Fatalf("MergeAll. got: %v, expected: %v", AsSlice(got), expected)
+	}
+	if err := checkSortedRangeInvariants(got); err != nil {
+		t.Fatalf("got: %v. %v", got, err)
+	}
+}
+
+func Test_MergeAll_Random(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	ranges := []SortedRange{}
+	expected := EmptyRange
+	for i := 0; i < 30; i++ {
+		r := &mutableRange{}
+		base := rng.Intn(1000)
+		for j := 0; j < 50; j++ {
+			r.Add(Elements{keyedElement{key: base + rng.Intn(100), seq: i}})
+		}
+		ranges = append(ranges, r.Freeze())
+		expected = Merge(expected, r.Freeze())
+	}
+
+	got := MergeAll(ranges...)
+	if err := checkSortedRangeInvariants(got); err != nil {
+		t.Fatalf("got: %v. %v", got, err)
+	}
+	if !reflect.DeepEqual(AsSlice(got), AsSlice(expected)) {
+		t.Fatalf("MergeAll. got: %v, expected: %v", AsSlice(got), AsSlice(expected))
+	}
+
+	for _, pivot := range []int{0, 250, 500, 750, 1100} {
+		p1, p2 := MergeAll(ranges...).Partition(keyedElement{key: pivot}, LessOrder)
+		e1, e2 := expected.Partition(keyedElement{key: pivot}, LessOrder)
+		if !reflect.DeepEqual(AsSlice(p1), AsSlice(e1)) || !reflect.DeepEqual(AsSlice(p2), AsSlice(e2)) {
+			t.Fatalf("partition at %d. got: %v, %v, expected: %v, %v", pivot, AsSlice(p1), AsSlice(p2), AsSlice(e1), AsSlice(e2))
+		}
+	}
+}
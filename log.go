@@ -0,0 +1,203 @@
+package tsl
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// generationalLog is a Log that accumulates writes in a chain of
+// UnsortedRange generations. Each call to Snapshot retires the current
+// generation, replacing it with a fresh one, and merges the frozen
+// generation into the history of the log.
+//
+// Writers only hold the log's mutex long enough to read the current
+// generation, so they are never blocked by the sorting and merging
+// activities of readers. A writer that races with a reader and finds
+// its generation frozen simply retries against the next generation.
+//
+// While an archive or a compaction is in progress, generations retired by
+// readers are also recorded in pending so that they can be merged into
+// what replaces the history once the archive or compaction is complete.
+type generationalLog struct {
+	mu          sync.Mutex       // guards current
+	current     UnsortedRange    // the generation that receives writes
+	readMu      sync.Mutex       // serializes readers and guards history, pending, policy, compacting and closed
+	history     SortedRange      // the merge of all retired generations
+	pending     []SortedRange    // generations retired during an archive, nil if none is in progress
+	archiveMu   sync.Mutex       // serializes archivers and compactions
+	resolve     Resolver         // combines equal elements
+	policy      CompactionPolicy // the policy used to compact the history
+	compacting  bool             // true while a background compaction is scheduled or running
+	closed      bool             // true once the log is closed, after which compactions are not started
+	compactions sync.WaitGroup   // tracks background compactions
+	tombstones  atomic.Bool      // true once a tombstone has been appended
+}
+
+func newGenerationalLog(resolve Resolver) *generationalLog {
+	return &generationalLog{
+		current: newMutableRange(resolve),
+		history: EmptyRange,
+		resolve: resolve,
+	}
+}
+
+// generation answers the generation that currently receives writes.
+func (l *generationalLog) generation() UnsortedRange {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.current
+}
+
+// rotate replaces the current generation with a new one and
+// answers the retired generation.
+func (l *generationalLog) rotate() UnsortedRange {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	retired := l.current
+	l.current = newMutableRange(l.resolve)
+	return retired
+}
+
+// Append adds the elements to the current generation, retrying
+// against the next generation if a reader froze the current one first.
+func (l *generationalLog) Append(elements []Element) error {
+	if !l.tombstones.Load() {
+		for _, e := range elements {
+			if isTombstone(e) {
+				l.tombstones.Store(true)
+				break
+			}
+		}
+	}
+	for {
+		if err := l.generation().Add(elements); err != ErrAlreadyFrozen {
+			return err
+		}
+	}
+}
+
+// Snapshot retires the current generation and merges it into the
+// history of the log. The sort of the retired generation is deferred
+// until the snapshot is first read or partitioned. The history is only
+// wrapped in a view that hides tombstones once a tombstone has been appended.
+func (l *generationalLog) Snapshot() SortedRange {
+	l.readMu.Lock()
+	defer l.readMu.Unlock()
+
+	l.retire()
+	if !l.compacting && !l.closed && l.policy != (CompactionPolicy{}) && l.policy.exceeded(ShapeOf(l.history)) {
+		l.compacting = true
+		l.compactions.Add(1)
+		go l.compact()
+	}
+	if !l.tombstones.Load() {
+		return l.history
+	}
+	return Visible(l.history)
+}
+
+func (l *generationalLog) SetCompactionPolicy(policy CompactionPolicy) {
+	l.readMu.Lock()
+	defer l.readMu.Unlock()
+	l.policy = policy
+}
+
+func (l *generationalLog) Close() {
+	l.readMu.Lock()
+	l.closed = true
+	l.readMu.Unlock()
+	l.compactions.Wait()
+}
+
+// compact replaces the history with a compacted history. Readers are not
+// blocked while the history is compacted.
+func (l *generationalLog) compact() {
+	defer l.compactions.Done()
+
+	l.archiveMu.Lock()
+	defer l.archiveMu.Unlock()
+
+	l.readMu.Lock()
+	policy := l.policy
+	l.readMu.Unlock()
+
+	l.replace(func(history SortedRange) (SortedRange, error) {
+		return CompactWith(history, policy), nil
+	})
+
+	l.readMu.Lock()
+	l.compacting = false
+	l.readMu.Unlock()
+}
+
+// DeleteRange retires the current generation and then removes [from, to) from the history.
+// Archivers are excluded so that the deleted elements cannot be restored by an archive
+// that started before the delete.
+func (l *generationalLog) DeleteRange(from Element, to Element) {
+	l.archiveMu.Lock()
+	defer l.archiveMu.Unlock()
+
+	l.readMu.Lock()
+	defer l.readMu.Unlock()
+
+	l.retire()
+	l.history = DeleteRange(l.history, from, to)
+}
+
+// retire merges the current generation into the history.
+// Must be called while holding readMu.
+func (l *generationalLog) retire() {
+	retired := l.rotate().Freeze()
+	if l.pending != nil {
+		l.pending = append(l.pending, retired)
+	}
+	l.history = merge(l.history, retired, l.resolve)
+}
+
+// Archive partitions the history of the log at the specified element and
+// passes the older partition to the archiver. Readers are not blocked
+// while the archiver runs. If the archiver succeeds, the history is
+// replaced by the newer partition merged with any generations that were
+// retired while the archiver was running.
+func (l *generationalLog) Archive(before Element, archiver Archiver) error {
+	l.archiveMu.Lock()
+	defer l.archiveMu.Unlock()
+
+	return l.replace(func(history SortedRange) (SortedRange, error) {
+		older, newer := history.Partition(before, LessOrder)
+		if older.Limit() > 0 {
+			if err := archiver.Archive(older); err != nil {
+				return nil, err
+			}
+		}
+		return newer, nil
+	})
+}
+
+// replace retires the current generation and then calls f, without holding readMu, to
+// obtain a replacement for the history. If f succeeds, the history is replaced by the
+// replacement merged with any generations that were retired while f was running.
+// Must be called while holding archiveMu.
+func (l *generationalLog) replace(f func(history SortedRange) (SortedRange, error)) error {
+	l.readMu.Lock()
+	l.retire()
+	history := l.history
+	l.pending = []SortedRange{}
+	l.readMu.Unlock()
+
+	replacement, err := f(history)
+
+	l.readMu.Lock()
+	defer l.readMu.Unlock()
+
+	pending := l.pending
+	l.pending = nil
+	if err != nil {
+		return err
+	}
+	for _, r := range pending {
+		replacement = merge(replacement, r, l.resolve)
+	}
+	l.history = replacement
+	return nil
+}
@@ -0,0 +1,185 @@
+package tsl
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func Test_Log_Empty(t *testing.T) {
+	l := NewLog()
+	got := l.Snapshot()
+	if err := checkSortedRangeInvariants(got); err != nil {
+		t.Fatalf("got: %v. %v", got, err)
+	}
+	if got.Limit() != 0 {
+		t.Fatalf("snapshot of empty log. got: %v, expected: %v", got, EmptyRange)
+	}
+}
+
+func Test_Log_Snapshots(t *testing.T) {
+	l := NewLog()
+	l.Append(NewElements([]int{3, 1, 2}))
+	first := l.Snapshot()
+	l.Append(NewElements([]int{5, 0, 2, 4}))
+	second := l.Snapshot()
+
+	got := Elements(AsSlice(first))
+	expected := NewElements([]int{1, 2, 3})
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("first snapshot. got: %v, expected: %v", got, expected)
+	}
+
+	got = Elements(AsSlice(second))
+	expected = NewElements([]int{0, 1, 2, 3, 4, 5})
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("second snapshot. got: %v, expected: %v", got, expected)
+	}
+	if err := checkSortedRangeInvariants(second); err != nil {
+		t.Fatalf("got: %v. %v", second, err)
+	}
+}
+
+func Test_Log_ConcurrentWritersAndReaders(t *testing.T) {
+	const writers = 4
+	const perWriter = 1000
+
+	l := NewLog()
+	wg := sync.WaitGroup{}
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				if err := l.Append([]Element{intElement{i*writers + w}}); err != nil {
+					t.Errorf("unexpected error: %v", err)
+					return
+				}
+			}
+		}(w)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			s := l.Snapshot()
+			slice := AsSlice(s)
+			for i := 1; i < len(slice); i++ {
+				if !slice[i-1].Less(slice[i]) {
+					t.Errorf("snapshot out of order at %d: %v, %v", i, slice[i-1], slice[i])
+					return
+				}
+			}
+			if len(slice) == writers*perWriter {
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+	<-done
+
+	got := len(AsSlice(l.Snapshot()))
+	expected := writers * perWriter
+	if got != expected {
+		t.Fatalf("final snapshot size. got: %d, expected: %d", got, expected)
+	}
+}
+
+// sliceArchiver is an Archiver that copies archived ranges into memory and
+// optionally runs a hook before returning.
+type sliceArchiver struct {
+	archived []Elements
+	during   func()
+	err      error
+}
+
+func (a *sliceArchiver) Archive(r SortedRange) error {
+	if a.during != nil {
+		a.during()
+	}
+	if a.err != nil {
+		return a.err
+	}
+	a.archived = append(a.archived, Elements(AsSlice(r)))
+	return nil
+}
+
+func Test_Log_Archive(t *testing.T) {
+	l := NewLog()
+	l.Append(NewElements([]int{4, 1, 3, 0, 2}))
+
+	a := &sliceArchiver{}
+	if err := l.Archive(intElement{2}, a); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []Elements{NewElements([]int{0, 1})}
+	if !reflect.DeepEqual(a.archived, expected) {
+		t.Fatalf("archived. got: %v, expected: %v", a.archived, expected)
+	}
+
+	got := Elements(AsSlice(l.Snapshot()))
+	expectedRemaining := NewElements([]int{2, 3, 4})
+	if !reflect.DeepEqual(got, expectedRemaining) {
+		t.Fatalf("remaining. got: %v, expected: %v", got, expectedRemaining)
+	}
+}
+
+func Test_Log_Archive_RetainsWritesDuringArchive(t *testing.T) {
+	l := NewLog()
+	l.Append(NewElements([]int{1, 3, 5}))
+
+	a := &sliceArchiver{
+		during: func() {
+			l.Append(NewElements([]int{0, 6}))
+			l.Snapshot()
+			l.Append(NewElements([]int{2}))
+		},
+	}
+	if err := l.Archive(intElement{4}, a); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []Elements{NewElements([]int{1, 3})}
+	if !reflect.DeepEqual(a.archived, expected) {
+		t.Fatalf("archived. got: %v, expected: %v", a.archived, expected)
+	}
+
+	got := Elements(AsSlice(l.Snapshot()))
+	expectedRemaining := NewElements([]int{0, 2, 5, 6})
+	if !reflect.DeepEqual(got, expectedRemaining) {
+		t.Fatalf("remaining. got: %v, expected: %v", got, expectedRemaining)
+	}
+}
+
+func Test_Log_Archive_Failure(t *testing.T) {
+	l := NewLog()
+	l.Append(NewElements([]int{2, 0, 1}))
+
+	failure := errors.New("disk full")
+	a := &sliceArchiver{err: failure}
+	if err := l.Archive(intElement{2}, a); err != failure {
+		t.Fatalf("archive error. got: %v, expected: %v", err, failure)
+	}
+
+	got := Elements(AsSlice(l.Snapshot()))
+	expected := NewElements([]int{0, 1, 2})
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("remaining. got: %v, expected: %v", got, expected)
+	}
+}
+
+func Test_Log_SnapshotDepth(t *testing.T) {
+	// each append overlaps the end of the previous one, so every snapshot partitions
+	// the disjoint ranges of the history
+	l := NewLog()
+	for i := 0; i < 35; i++ {
+		l.Append(NewElements(sequence(10*i, 10*i+15, 1)))
+		if s := ShapeOf(l.Snapshot()); s.Depth > 3 {
+			t.Fatalf("snapshot %d should not nest disjoint ranges. got: %+v", i, s)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package tsl
 
 import (
 	"fmt"
+	"sort"
 )
 
 // disjointRanges represents a slice of SortedRanges which do not
@@ -30,6 +31,40 @@ func (d *disjointRanges) Open() Cursor {
 	}
 }
 
+func (d *disjointRanges) children() []SortedRange {
+	return d.segments
+}
+
+// Count answers the sum of the counts of the segments.
+func (d *disjointRanges) Count() int {
+	count := 0
+	for _, r := range d.segments {
+		count += Count(r)
+	}
+	return count
+}
+
+// Get uses a binary search to find the only segment that might contain key.
+func (d *disjointRanges) Get(key Element) (Element, bool) {
+	i := sort.Search(len(d.segments), func(i int) bool {
+		return !d.segments[i].Last().Less(key)
+	})
+	if i == len(d.segments) || key.Less(d.segments[i].First()) {
+		return nil, false
+	}
+	return Get(d.segments[i], key)
+}
+
+// OpenReverse opens a cursor that walks the segments backwards.
+func (d *disjointRanges) OpenReverse() Cursor {
+	last := len(d.segments) - 1
+	return &disjointReverseCursor{
+		next:     last,
+		cursor:   OpenReverse(d.segments[last]),
+		segments: d.segments,
+	}
+}
+
 func (d *disjointRanges) Limit() int {
 	limit := 0
 	for _, r := range d.segments {
@@ -38,79 +73,74 @@ func (d *disjointRanges) Limit() int {
 	return limit
 }
 
+// Partition uses a binary search to find the first segment that is not entirely ordered
+// before e. The receiver is split either before that segment or, by partitioning the
+// segment itself, within it.
 func (d *disjointRanges) Partition(e Element, o Order) (SortedRange, SortedRange) {
 	if !o(d.first, e) {
 		return EmptyRange, d
 	}
 
-	for i, r := range d.segments {
-		if r.First() == nil {
-			panic("r.First() is nil!")
-		}
-		if r.Last() == nil {
-			panic("r.Last() is nil!")
+	i := sort.Search(len(d.segments), func(i int) bool {
+		return !o(d.segments[i].Last(), e)
+	})
+	if i == len(d.segments) {
+		return d, EmptyRange
+	}
+
+	r := d.segments[i]
+	if !o(r.First(), e) {
+		return d.slice(0, i), d.slice(i, len(d.segments))
+	}
+
+	// the segments either side of the partitioned segment are copied into flat slices,
+	// rather than nested within new disjoint ranges, so that repeated partitions and
+	// merges do not deepen the tree.
+	p1, p2 := r.Partition(e, o)
+	if p1.Last() == nil {
+		panic("p1.Last() is nil!")
+	}
+	var r1, r2 SortedRange = p1, useEmptyRangeIfEmpty(p2)
+	if i > 0 {
+		segments := make([]SortedRange, 0, i+1)
+		r1 = &disjointRanges{
+			first:    d.first,
+			last:     p1.Last(),
+			segments: append(append(segments, d.segments[0:i]...), p1),
 		}
-		if o(e, r.First()) && !o(r.First(), e) {
-			r1, r2 := &disjointRanges{
-				first:    d.first,
-				last:     d.segments[i-1].Last(),
-				segments: d.segments[0:i],
-			}, &disjointRanges{
-				first:    r.First(),
+	}
+	if i+1 < len(d.segments) {
+		if r2 == EmptyRange {
+			r2 = d.slice(i+1, len(d.segments))
+		} else {
+			segments := make([]SortedRange, 0, len(d.segments)-i)
+			r2 = &disjointRanges{
+				first:    p2.First(),
 				last:     d.last,
-				segments: d.segments[i:],
+				segments: append(append(segments, p2), d.segments[i+1:]...),
 			}
-			return r1, r2
-		}
-		if o(r.First(), e) && o(e, r.Last()) {
-			p1, p2 := r.Partition(e, o)
-			var r1, r2 SortedRange
-			if p2.Limit() == 0 {
-				if p1.Last() == nil {
-					panic("p1.Last() is nil!")
-				}
-				r1 = &disjointRanges{
-					first:    d.first,
-					last:     p1.Last(),
-					segments: append(d.segments[0:i], p1),
-				}
-				if i+1 < len(d.segments) {
-					r2 = &disjointRanges{
-						first:    d.segments[i+1].First(),
-						last:     d.last,
-						segments: d.segments[i+1:],
-					}
-				} else {
-					r2 = EmptyRange
-				}
-			} else {
-				if p1.Last() == nil {
-					panic("p1.Last() is nil!")
-				}
-				if p2.First() == nil {
-					panic("p2.First() is nil!")
-				}
-				r1, r2 = &disjointRanges{
-					first:    d.first,
-					last:     p1.Last(),
-					segments: append(d.segments[0:i], p1),
-				}, &disjointRanges{
-					first:    p2.First(),
-					last:     d.last,
-					segments: append([]SortedRange{p2}, d.segments[i+1:]...),
-				}
-			}
-			return r1, r2
 		}
 	}
+	return r1, r2
+}
 
-	return d, EmptyRange
+// slice answers the segments in [lo, hi) as a single SortedRange.
+func (d *disjointRanges) slice(lo int, hi int) SortedRange {
+	if hi-lo == 1 {
+		return d.segments[lo]
+	}
+	return &disjointRanges{
+		first:    d.segments[lo].First(),
+		last:     d.segments[hi-1].Last(),
+		segments: d.segments[lo:hi],
+	}
 }
 
 type disjointCursor struct {
 	next     int
 	cursor   Cursor
 	segments []SortedRange
+	err      error // the error that stopped the iteration, if any
 }
 
 func (c *disjointCursor) nextCursor() Cursor {
@@ -122,12 +152,25 @@ func (c *disjointCursor) nextCursor() Cursor {
 	}
 }
 
+// advance moves to the next segment once the cursor over the current segment is
+// exhausted, unless that cursor stopped because of an error, in which case the
+// iteration stops and the error is retained.
+func (c *disjointCursor) advance() {
+	if c.err = cursorErr(c.cursor); c.err != nil {
+		closeCursor(c.cursor)
+		c.cursor = nil
+		c.next = len(c.segments)
+		return
+	}
+	c.cursor = c.nextCursor()
+}
+
 func (c *disjointCursor) Next() Element {
 	var next Element
 	for c.cursor != nil {
 		next = c.cursor.Next()
 		if next == nil {
-			c.cursor = c.nextCursor()
+			c.advance()
 		} else {
 			break
 		}
@@ -142,12 +185,119 @@ func (c *disjointCursor) Fill(buffer []Element) int {
 		filled := c.cursor.Fill(buffer[next:max])
 		next += filled
 		if next < max {
-			c.cursor = c.nextCursor()
+			c.advance()
 		}
 	}
 	return next
 }
 
+// Seek skips the segments whose elements all sort before e and then seeks
+// within the first segment that is not skipped.
+func (c *disjointCursor) Seek(e Element) {
+	if c.cursor == nil {
+		return
+	}
+	if c.segments[c.next].Last().Less(e) {
+		remaining := c.segments[c.next+1:]
+		c.next += 1 + sort.Search(len(remaining), func(i int) bool {
+			return !remaining[i].Last().Less(e)
+		})
+		if c.next == len(c.segments) {
+			c.cursor = nil
+			return
+		}
+		c.cursor = c.segments[c.next].Open()
+	}
+	s := Seekable(c.cursor)
+	s.Seek(e)
+	c.cursor = s
+}
+
+// Err answers the error that stopped the iteration or, failing that, the error
+// encountered by the cursor over the current segment, if any.
+func (c *disjointCursor) Err() error {
+	if c.err != nil || c.cursor == nil {
+		return c.err
+	}
+	return cursorErr(c.cursor)
+}
+
+// Close closes the cursor over the current segment and ends the iteration.
+func (c *disjointCursor) Close() error {
+	if c.cursor == nil {
+		return c.err
+	}
+	err := closeCursor(c.cursor)
+	c.cursor = nil
+	c.next = len(c.segments)
+	return err
+}
+
+// disjointReverseCursor iterates backwards over disjoint ranges, starting with the last segment.
+type disjointReverseCursor struct {
+	next     int
+	cursor   Cursor
+	segments []SortedRange
+	err      error // the error that stopped the iteration, if any
+}
+
+func (c *disjointReverseCursor) nextCursor() Cursor {
+	c.next--
+	if c.next >= 0 {
+		return OpenReverse(c.segments[c.next])
+	} else {
+		return nil
+	}
+}
+
+// advance moves to the previous segment once the cursor over the current segment is
+// exhausted, unless that cursor stopped because of an error, in which case the
+// iteration stops and the error is retained.
+func (c *disjointReverseCursor) advance() {
+	if c.err = cursorErr(c.cursor); c.err != nil {
+		closeCursor(c.cursor)
+		c.cursor = nil
+		c.next = -1
+		return
+	}
+	c.cursor = c.nextCursor()
+}
+
+func (c *disjointReverseCursor) Next() Element {
+	var next Element
+	for c.cursor != nil {
+		next = c.cursor.Next()
+		if next == nil {
+			c.advance()
+		} else {
+			break
+		}
+	}
+	return next
+}
+
+func (c *disjointReverseCursor) Fill(buffer []Element) int {
+	max := len(buffer)
+	next := 0
+	for next < max && c.cursor != nil {
+		filled := c.cursor.Fill(buffer[next:max])
+		next += filled
+		if next < max {
+			c.advance()
+		}
+	}
+	return next
+}
+
+// Err answers the error that stopped the iteration or, failing that, the error
+// encountered by the cursor over the current segment, if any.
+func (c *disjointReverseCursor) Err() error {
+	if c.err != nil || c.cursor == nil {
+		return c.err
+	}
+	return cursorErr(c.cursor)
+}
+
 // selectFirst Choose r = a.First() or r = b.First() such that
 // !b.First().Less(r) && !a.First().Less(r).
 //
@@ -178,28 +328,32 @@ func selectLast(a, b Range) Element {
 	return a.Last()
 }
 
-// flatten merges the segments slices of adjacent disjointRanges into a larger
-// slice
+// flatten answers the segments with any disjointRanges, at any depth, replaced by
+// their own segments.
 func flatten(segments []SortedRange) []SortedRange {
-	if len(segments) == 0 {
-		return segments
-	} else if _, ok := segments[0].(*disjointRanges); !ok {
-		return append([]SortedRange{segments[0]}, flatten(segments[1:])...)
-	} else {
-		tmp := []SortedRange{}
-		for i, r := range segments {
-			if d, ok := r.(*disjointRanges); ok {
-				tmp = append(tmp, d.segments...)
-			} else {
-				return append(tmp, flatten(segments[i:])...)
-			}
+	flat := make([]SortedRange, 0, len(segments))
+	for _, r := range segments {
+		if d, ok := r.(*disjointRanges); ok {
+			flat = append(flat, flatten(d.segments)...)
+		} else {
+			flat = append(flat, r)
 		}
-		return tmp
 	}
+	return flat
+}
 
+// apply answers resolve(older, newer) or, if resolve is nil or either
+// element is a Tombstone, newer.
+func (resolve Resolver) apply(older Element, newer Element) Element {
+	if resolve == nil || isTombstone(newer) || isTombstone(older) {
+		return newer
+	}
+	return resolve(older, newer)
 }
 
-func merge(a SortedRange, b SortedRange) SortedRange {
+// merge merges a and b, combining equal elements with the specified resolver. Only the
+// overlapping parts of a and b are merged; the other parts are concatenated.
+func merge(a SortedRange, b SortedRange, resolve Resolver) SortedRange {
 	if a.Limit() == 0 {
 		return b
 	} else if b.Limit() == 0 {
@@ -224,7 +378,7 @@ func merge(a SortedRange, b SortedRange) SortedRange {
 		} else if p3.Limit() == 0 {
 			m23 = p2
 		} else {
-			m23 = useEmptyRangeIfEmpty(newMergeableRange(selectFirst(p2, p3), selectLast(p2, p3), p2, p3, nil))
+			m23 = useEmptyRangeIfEmpty(newMergeableRange(selectFirst(p2, p3), selectLast(p2, p3), p2, p3, nil, resolve))
 		}
 
 		segments := []SortedRange{p1, m23, p4}
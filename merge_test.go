@@ -252,3 +252,83 @@ func Test_Merge_Pair1_Pair2_Pair3(t *testing.T) {
 		t.Fatalf("partition got: %v, expected :%v", AsSlice(got), expected)
 	}
 }
+
+func Test_Merge_Disjoint_PartitionTwice(t *testing.T) {
+	a := newImmutableRange(NewElements([]int{0, 1}))
+	b := newImmutableRange(NewElements([]int{2, 3}))
+	c := newImmutableRange(NewElements([]int{4, 5}))
+	merged := Merge(Merge(a, b), c)
+
+	merged.Partition(intElement{3}, LessOrder)
+	merged.Partition(intElement{1}, LessOrder)
+
+	expected := NewElements([]int{0, 1, 2, 3, 4, 5})
+	if !reflect.DeepEqual(Elements(AsSlice(merged)), expected) {
+		t.Fatalf("partition modified the receiver. got: %v, expected :%v", AsSlice(merged), expected)
+	}
+}
+
+func Test_Merge_Disjoint_Partition_AtLast(t *testing.T) {
+	a := newImmutableRange(NewElements([]int{0, 1}))
+	b := newImmutableRange(NewElements([]int{3, 4, 5}))
+	merged := Merge(a, b)
+
+	p1, p2 := merged.Partition(intElement{5}, LessOrder)
+	expected := NewElements([]int{0, 1, 3, 4})
+	if !reflect.DeepEqual(Elements(AsSlice(p1)), expected) {
+		t.Fatalf("partition p1. got: %v, expected :%v", AsSlice(p1), expected)
+	}
+	expected = NewElements([]int{5})
+	if !reflect.DeepEqual(Elements(AsSlice(p2)), expected) {
+		t.Fatalf("partition p2. got: %v, expected :%v", AsSlice(p2), expected)
+	}
+}
+
+// disjointSegments answers disjoint ranges of n segments, each of which holds the
+// even numbers in [10*i, 10*i+10).
+func disjointSegments(n int) SortedRange {
+	segments := make([]SortedRange, n)
+	for i := range segments {
+		segments[i] = newImmutableRange(NewElements(sequence(10*i, 10*i+10, 2)))
+	}
+	return MergeAll(segments...)
+}
+
+func Test_Merge_Disjoint_PartitionMany(t *testing.T) {
+	r := disjointSegments(50)
+	all := AsSlice(r)
+	for k := -1; k <= 501; k++ {
+		e := intElement{k}
+		for _, o := range []Order{LessOrder, LessOrEqualOrder} {
+			expected1, expected2 := Elements{}, Elements{}
+			for _, x := range all {
+				if o(x, e) {
+					expected1 = append(expected1, x)
+				} else {
+					expected2 = append(expected2, x)
+				}
+			}
+			p1, p2 := r.Partition(e, o)
+			if !reflect.DeepEqual(Elements(AsSlice(p1)), expected1) {
+				t.Fatalf("partition p1 at %v. got: %v, expected :%v", e, AsSlice(p1), expected1)
+			}
+			if !reflect.DeepEqual(Elements(AsSlice(p2)), expected2) {
+				t.Fatalf("partition p2 at %v. got: %v, expected :%v", e, AsSlice(p2), expected2)
+			}
+			for _, p := range []SortedRange{p1, p2} {
+				if err := checkSortedRangeInvariants(p); err != nil {
+					t.Fatalf("got: %v. %v", p, err)
+				}
+			}
+		}
+	}
+}
+
+func Benchmark_DisjointRanges_Partition(b *testing.B) {
+	const n = 4096
+	r := disjointSegments(n)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Partition(intElement{(i * 7919) % (10 * n)}, LessOrder)
+	}
+}
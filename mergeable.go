@@ -22,6 +22,8 @@ type mergeableRange struct {
 	rx       *mergeCursor // a peekable cursor into the right arm of the merge
 	mx       int          // number of copied elements
 	nx       int          // number of deduplicated elements
+	resolve  Resolver     // combines equal elements from the left and right arms
+	progress *sync.Cond   // signalled as a parallel merge advances, nil unless one is in progress
 }
 
 // mergeOne advances nx so that it represents the length of the merged, deduplicated slice and advances
@@ -30,6 +32,14 @@ func (r *mergeableRange) mergeOne() {
 	if r.left == nil {
 		return
 	}
+	if r.progress != nil {
+		// wait for the parallel merge to advance
+		progress, nx := r.progress, r.nx
+		for r.progress != nil && r.nx == nx {
+			progress.Wait()
+		}
+		return
+	}
 	if r.rx == nil {
 		panic("illegal state: r.rx is nil")
 	}
@@ -45,15 +55,15 @@ func (r *mergeableRange) mergeOne() {
 		} else if leftPeek == nil || rightPeek.Less(leftPeek) {
 			r.elements[r.mx] = r.rx.next()
 		} else {
-			r.elements[r.mx] = r.rx.next()
-			r.lx.next()
+			older := r.lx.next()
+			r.elements[r.mx] = r.resolve.apply(older, r.rx.next())
 		}
 
 		if r.mx > 0 {
 			if r.elements[r.mx-1].Less(r.elements[r.mx]) {
 				r.mx++
 			} else {
-				r.elements[r.mx-1] = r.elements[r.mx]
+				r.elements[r.mx-1] = r.resolve.apply(r.elements[r.mx-1], r.elements[r.mx])
 				r.elements[r.mx] = nil
 			}
 		} else {
@@ -72,7 +82,7 @@ func (r *mergeableRange) mergeOne() {
 	}
 }
 
-func newMergeableRange(first Element, last Element, left SortedRange, right SortedRange, unsorted *unsortedRange) *mergeableRange {
+func newMergeableRange(first Element, last Element, left SortedRange, right SortedRange, unsorted *unsortedRange, resolve Resolver) *mergeableRange {
 
 	if last == nil {
 		if first != nil || left.Limit() != 0 || right.Limit() != 0 {
@@ -86,6 +96,7 @@ func newMergeableRange(first Element, last Element, left SortedRange, right Sort
 					elements: nil,
 				},
 			},
+			resolve: resolve,
 		}
 	}
 
@@ -119,7 +130,8 @@ func newMergeableRange(first Element, last Element, left SortedRange, right Sort
 		lx: &mergeCursor{
 			underlying: left.Open(),
 		},
-		rx: rx,
+		rx:      rx,
+		resolve: resolve,
 	}
 }
 
@@ -157,6 +169,98 @@ func (r *mergeableRange) Open() Cursor {
 	}
 }
 
+// Limit answers the length of the merged slice, which is truncated once the merge is complete.
+func (r *mergeableRange) Limit() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.elements)
+}
+
+// Count completes the merge, if it is not already complete, and answers the number
+// of merged elements.
+func (r *mergeableRange) Count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.left != nil && r.right == nil {
+		r.freeze()
+	}
+	for r.left != nil {
+		r.mergeOne()
+	}
+	return len(r.elements)
+}
+
+// Get searches the merged part of the range if key sorts within it. Otherwise, it looks
+// up key in both arms of the merge and combines the results as the merge would, so
+// the merge is not advanced.
+func (r *mergeableRange) Get(key Element) (Element, bool) {
+	r.mu.Lock()
+	if r.left != nil && r.right == nil {
+		r.freeze()
+	}
+	left, right := r.left, r.right
+	merged := r.elements[0:r.nx]
+	r.mu.Unlock()
+
+	if left == nil || (len(merged) > 0 && !merged[len(merged)-1].Less(key)) {
+		return get(merged, key)
+	}
+
+	older, inLeft := Get(left, key)
+	newer, inRight := Get(right, key)
+	switch {
+	case inLeft && inRight:
+		return r.resolve.apply(older, newer), true
+	case inLeft:
+		return older, true
+	case inRight:
+		return newer, true
+	default:
+		return nil, false
+	}
+}
+
+// children answers the arms of the merge, or nil if the merge is complete. An unsorted
+// arm is answered as an empty range so that it is not sorted before it is needed.
+func (r *mergeableRange) children() []SortedRange {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.left == nil {
+		return nil
+	} else if r.right == nil {
+		return []SortedRange{r.left, emptyRange}
+	}
+	return []SortedRange{r.left, r.right}
+}
+
+// immutable completes the merge and answers an immutableRange over the merged slice.
+func (r *mergeableRange) immutable() SortedRange {
+	r.Count()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return newImmutableRange(r.elements)
+}
+
+// OpenReverse opens a cursor over the result of the merge if the merge is complete.
+// Otherwise, the cursor merges the arms from their last elements without advancing,
+// or waiting for, the forward merge.
+func (r *mergeableRange) OpenReverse() Cursor {
+	r.mu.Lock()
+	if r.left != nil && r.right == nil {
+		r.freeze()
+	}
+	left, right := r.left, r.right
+	r.mu.Unlock()
+
+	if left == nil {
+		return r.immutableRange.OpenReverse()
+	}
+	return newReverseMergeCursor([]SortedRange{left, right}, r.resolve)
+}
+
 func useEmptyRangeIfEmpty(s SortedRange) SortedRange {
 	if s.Limit() == 0 {
 		return EmptyRange
@@ -180,19 +284,18 @@ func (r *mergeableRange) Partition(e Element, o Order) (SortedRange, SortedRange
 	if r.left != nil && r.right == nil {
 		r.freeze()
 	}
-	if r.left == nil {
-		return r.immutableRange.Partition(e, o)
-	}
+	left, right := r.left, r.right
 	r.mu.Unlock()
 
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+	if left == nil {
+		return r.immutableRange.Partition(e, o)
+	}
 
-	p1, p2 := r.left.Partition(e, o)
-	p3, p4 := r.right.Partition(e, o)
+	p1, p2 := left.Partition(e, o)
+	p3, p4 := right.Partition(e, o)
 
-	return useEmptyRangeIfEmpty(newMergeableRange(selectFirst(p1, p3), selectLast(p1, p3), p1, p3, nil)),
-		useEmptyRangeIfEmpty(newMergeableRange(selectFirst(p2, p4), selectLast(p2, p4), p2, p4, nil))
+	return useEmptyRangeIfEmpty(newMergeableRange(selectFirst(p1, p3), selectLast(p1, p3), p1, p3, nil, r.resolve)),
+		useEmptyRangeIfEmpty(newMergeableRange(selectFirst(p2, p4), selectLast(p2, p4), p2, p4, nil, r.resolve))
 }
 
 func (r *mergeableRange) String() string {
@@ -237,8 +340,8 @@ func (c *mergeableCursor) Next() Element {
 	return c.elements[c.next]
 }
 
-// Fill copies the merged part of the range with a slice copy, then iterates
-// over the unmerged part.
+// Fill copies the merged part of the range with a slice copy, then advances the
+// merge to fill the rest of the buffer.
 func (c *mergeableCursor) Fill(buffer []Element) int {
 	max := len(buffer)
 	next := 0
@@ -256,19 +359,107 @@ func (c *mergeableCursor) Fill(buffer []Element) int {
 
 	next = limit
 	c.next += limit
+	if next == max {
+		return max
+	}
 
-	// iterate over the remainder of the range, upto the
-	// the buffer limit
-	for next < max {
-		e := c.Next()
-		if e != nil {
-			buffer[next] = e
-			next++
-		} else {
-			return next
+	// advance the merge far enough to fill the rest of the buffer while holding the
+	// write lock once, rather than once per element, then copy the merged elements
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for *c.nx-c.next < max-next {
+		nx := *c.nx
+		c.advance()
+		if *c.nx == nx {
+			break
+		}
+	}
+	limit = *c.nx - c.next
+	if limit > max-next {
+		limit = max - next
+	}
+	copy(buffer[next:], c.elements[c.next:c.next+limit])
+	c.next += limit
+	return next + limit
+}
+
+// Seek uses a binary search to skip over the merged part of the range. If e sorts after
+// the merged part, the merge is advanced until it reaches e. The elements that are
+// skipped in this way are still merged since the merged slice is shared with other cursors.
+func (c *mergeableCursor) Seek(e Element) {
+	c.mu.RLock()
+	c.next = seek(c.elements, c.next, *c.nx, e)
+	found := c.next < *c.nx
+	c.mu.RUnlock()
+
+	if found {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		c.next = seek(c.elements, c.next, *c.nx, e)
+		if c.next < *c.nx {
+			return
+		}
+		merged := *c.nx
+		c.advance()
+		if *c.nx == merged {
+			return
+		}
+	}
+}
+
+// reverseMergeCursor merges the elements of several ranges in descending order. The
+// arms are listed from oldest to newest and equal elements are combined in that order.
+// Each step costs O(k) comparisons for k arms.
+type reverseMergeCursor struct {
+	arms    []*mergeCursor
+	resolve Resolver
+}
+
+func newReverseMergeCursor(ranges []SortedRange, resolve Resolver) *reverseMergeCursor {
+	arms := make([]*mergeCursor, len(ranges))
+	for i, r := range ranges {
+		arms[i] = &mergeCursor{underlying: OpenReverse(r)}
+	}
+	return &reverseMergeCursor{
+		arms:    arms,
+		resolve: resolve,
+	}
+}
+
+func (c *reverseMergeCursor) Next() Element {
+	var max Element
+	for _, a := range c.arms {
+		if p := a.peek(); p != nil && (max == nil || max.Less(p)) {
+			max = p
+		}
+	}
+	if max == nil {
+		return nil
+	}
+	var e Element
+	for _, a := range c.arms {
+		if p := a.peek(); p != nil && !p.Less(max) {
+			if e == nil {
+				e = a.next()
+			} else {
+				e = c.resolve.apply(e, a.next())
+			}
+		}
+	}
+	return e
+}
+
+func (c *reverseMergeCursor) Fill(buffer []Element) int {
+	for i := range buffer {
+		if buffer[i] = c.Next(); buffer[i] == nil {
+			return i
 		}
 	}
-	return max
+	return len(buffer)
 }
 
 // mergeCursor is a cursor that iterates over the sorted, deduplicated elements
@@ -1,6 +1,7 @@
 package tsl
 
 import (
+	"sort"
 	"sync"
 )
 
@@ -10,12 +11,24 @@ import (
 // arrive out of order, a mutex to guard access to the mutable
 // state and frozen reference which is initialized with the
 // SortedRange that may used to access to the contents of the
-// range after it is has been frozen.
+// range after it is has been frozen. Equal elements are combined
+// with the resolver when the range is frozen. A nil resolver is
+// equivalent to LastWins.
 type mutableRange struct {
 	basicRange
 	mu       sync.RWMutex
 	unsorted unsortedRange
 	frozen   SortedRange
+	resolve  Resolver
+}
+
+func newMutableRange(resolve Resolver) *mutableRange {
+	return &mutableRange{
+		unsorted: unsortedRange{
+			resolve: resolve,
+		},
+		resolve: resolve,
+	}
 }
 
 func (r *mutableRange) First() Element {
@@ -36,16 +49,34 @@ func (r *mutableRange) Limit() int {
 	return len(r.elements) + r.unsorted.Limit()
 }
 
+// insertionWindow is the number of the most recently sorted elements of a mutableRange
+// among which an element that arrives out of order is inserted directly, rather than
+// added to the unsorted range to be sorted and merged when the range is frozen.
+const insertionWindow = 1024
+
+// addOne adds an element to the sorted elements, if it sorts after them or within the
+// insertion window, or to the unsorted range otherwise. The caller must hold the write
+// locks of both.
 func (r *mutableRange) addOne(e Element) {
 	if len(r.elements) > 0 {
-		if !r.last.Less(e) {
-			r.unsorted.add(e)
-		} else {
+		// only an element that does not sort after the last element can be the first
+		if r.last.Less(e) {
+			r.grow()
 			r.elements = append(r.elements, e)
 			r.last = e
-		}
-		if e.Less(r.first) {
-			r.first = e
+		} else if i := r.insertionPoint(e); i < 0 {
+			r.unsorted.addLocked(e)
+			if e.Less(r.first) {
+				r.first = e
+			}
+		} else {
+			r.grow()
+			r.elements = append(r.elements, nil)
+			copy(r.elements[i+1:], r.elements[i:])
+			r.elements[i] = e
+			if i == 0 && e.Less(r.first) {
+				r.first = e
+			}
 		}
 	} else {
 		r.elements = []Element{e}
@@ -54,6 +85,49 @@ func (r *mutableRange) addOne(e Element) {
 	}
 }
 
+// grow doubles the capacity of the sorted elements once they are full. Unlike append, which
+// grows large slices by a smaller factor, this copies each element at most twice on average.
+func (r *mutableRange) grow() {
+	if len(r.elements) == cap(r.elements) {
+		r.elements = append(make([]Element, 0, 2*cap(r.elements)), r.elements...)
+	}
+}
+
+// insertionPoint answers the index of the first sorted element that e, which does not
+// sort after the last sorted element, sorts before. The search gallops backwards from the
+// last element, in steps that start at 8 elements, so elements that are only slightly late
+// are found with few comparisons.
+// It answers -1 if e is equal to a sorted element, which is left to the resolver when the
+// range is frozen, or if e does not sort after the element before the insertion window.
+func (r *mutableRange) insertionPoint(e Element) int {
+	n := len(r.elements)
+	floor := n - insertionWindow
+	if floor < 0 {
+		floor = 0
+	}
+
+	// e sorts before or is equal to elements[hi], and after elements[lo] if lo >= floor
+	hi := n - 1
+	lo := hi - 8
+	for step := 16; lo >= floor && !r.elements[lo].Less(e); step *= 2 {
+		hi = lo
+		lo = hi - step
+	}
+	if lo < floor {
+		lo = floor - 1
+	}
+	i := lo + 1 + sort.Search(hi-lo-1, func(k int) bool {
+		return !r.elements[lo+1+k].Less(e)
+	})
+
+	if i == floor && floor > 0 && !r.elements[floor-1].Less(e) {
+		return -1
+	} else if !e.Less(r.elements[i]) {
+		return -1
+	}
+	return i
+}
+
 func (r *mutableRange) Add(elements []Element) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -62,6 +136,9 @@ func (r *mutableRange) Add(elements []Element) error {
 		return ErrAlreadyFrozen
 	}
 
+	// the unsorted range is locked once for the batch rather than once per element
+	r.unsorted.mu.Lock()
+	defer r.unsorted.mu.Unlock()
 	for _, e := range elements {
 		r.addOne(e)
 	}
@@ -84,7 +161,8 @@ func (r *mutableRange) Freeze() SortedRange {
 					},
 				},
 				nil,
-				&r.unsorted)
+				&r.unsorted,
+				r.resolve)
 		} else {
 			r.frozen = &immutableRange{
 				basicRange: basicRange{
@@ -3,6 +3,7 @@ package tsl
 import (
 	"reflect"
 	"testing"
+	"time"
 )
 
 func Test_MutableRange_Empty(t *testing.T) {
@@ -277,3 +278,63 @@ func Test_MutableRange_PartitionThenOpen(t *testing.T) {
 		t.Fatalf("partition failed got: %+v, expected: %+v", got, expected)
 	}
 }
+
+func Test_MutableRange_PartitionMerged(t *testing.T) {
+	r := Merge(newImmutableRange(NewElements([]int{1, 2, 4})), newImmutableRange(NewElements([]int{0, 3})))
+	c := r.Open()
+	for c.Next() != nil {
+	}
+	if m, ok := r.(*mergeableRange); !ok || m.left != nil {
+		t.Fatalf("the merge should be complete. got: %v", r)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, k := range []int{2, 3} {
+			p1, p2 := r.Partition(intElement{k}, LessOrder)
+			got := append(AsSlice(p1), AsSlice(p2)...)
+			expected := NewElements([]int{0, 1, 2, 3, 4})
+			if !reflect.DeepEqual(Elements(got), expected) {
+				t.Errorf("partition at %d. got: %v, expected: %v", k, got, expected)
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("partition of a merged range should release the lock of the range")
+	}
+}
+
+func Test_MutableRange_InsertionWindow(t *testing.T) {
+	// late elements within the insertion window are inserted into the sorted elements
+	u := NewUnsortedRange()
+	u.Add(NewElements([]int{0, 2, 4, 6, 8, 10}))
+	u.Add(NewElements([]int{7, 1, 9, 3, 5}))
+	r := u.Freeze()
+	if _, ok := r.(*immutableRange); !ok {
+		t.Fatalf("late elements within the window should not be merged. got: %v", r)
+	}
+	if got, expected := Elements(AsSlice(r)), NewElements(sequence(0, 11, 1)); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("within the window. got: %v, expected: %v", got, expected)
+	}
+
+	// late elements before the window, and equal elements, are merged when frozen
+	u = NewUnsortedRangeWith(sum)
+	for i := 1; i <= insertionWindow+1; i++ {
+		u.Add(keyed(i, 1))
+	}
+	u.Add(keyed(0, 1, insertionWindow, 10))
+	r = u.Freeze()
+	if _, ok := r.(*mergeableRange); !ok {
+		t.Fatalf("late elements before the window should be merged. got: %v", Inspect(r))
+	}
+	got := Elements(AsSlice(r))
+	if len(got) != insertionWindow+2 || got[0] != keyed(0, 1)[0] || got[insertionWindow] != keyed(insertionWindow, 11)[0] {
+		t.Fatalf("before the window. got: %v, %v, %v", len(got), got[0], got[insertionWindow])
+	}
+	if err := checkSortedRangeInvariants(r); err != nil {
+		t.Fatalf("got: %v. %v", r, err)
+	}
+}
@@ -0,0 +1,208 @@
+package tsl
+
+import (
+	"runtime"
+	"sync"
+)
+
+// MergeInBackground completes the merges within r in the background, using up to parallelism
+// goroutines for each merge of two ranges, and answers a channel that is closed once they are
+// complete. If parallelism is not positive, GOMAXPROCS goroutines are used.
+//
+// Each merge of two ranges is completed by splitting the unmerged parts of its arms at matching
+// pivots and merging the pieces concurrently into the merged slice of the range. Cursors continue
+// to read the merged part of the range while the pieces are merged, and cursors that reach the end
+// of the merged part wait for the next piece to be merged rather than merging elements themselves.
+// The k-way merges answered by MergeAll are completed by a single goroutine.
+func MergeInBackground(r SortedRange, parallelism int) <-chan struct{} {
+	if parallelism <= 0 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, m := range pendingMerges(r, nil) {
+			switch t := m.(type) {
+			case *mergeableRange:
+				t.mergeInParallel(parallelism)
+			case *kwayRange:
+				t.complete()
+			}
+		}
+	}()
+	return done
+}
+
+// pendingMerges appends the mergeableRanges and kwayRanges of the tree rooted at r to merges.
+// The arms of each merge are not searched since they are consumed by the merge.
+func pendingMerges(r SortedRange, merges []SortedRange) []SortedRange {
+	switch t := r.(type) {
+	case *mergeableRange, *kwayRange:
+		merges = append(merges, t)
+	case *visibleRange:
+		merges = pendingMerges(t.underlying, merges)
+	case *disjointRanges:
+		for _, s := range t.segments {
+			merges = pendingMerges(s, merges)
+		}
+	}
+	return merges
+}
+
+// piece is one of the pieces of a parallel merge. The merge of the left and right
+// parts of the arms is written into elements[offset:offset+limit].
+type piece struct {
+	left   SortedRange
+	right  SortedRange
+	offset int
+	limit  int
+	count  int           // the number of merged elements
+	done   chan struct{} // closed once the piece is merged
+}
+
+// mergeInParallel completes the merge by merging pieces of the unmerged parts of the
+// arms concurrently. The pieces are copied into place, and exposed to cursors, in order.
+func (r *mergeableRange) mergeInParallel(parallelism int) {
+	r.mu.Lock()
+	if r.left != nil && r.right == nil {
+		r.freeze()
+	}
+	if r.left != nil && r.progress != nil {
+		// another parallel merge is in progress, so wait for it to complete.
+		for progress := r.progress; r.progress != nil; {
+			progress.Wait()
+		}
+	}
+	if r.left == nil {
+		r.mu.Unlock()
+		return
+	}
+
+	// the arms are deduplicated, so the copied elements are final.
+	left, right := unmerged(r.left, r.lx), unmerged(r.right, r.rx)
+	elements, merged := r.elements, r.mx
+	r.nx = r.mx
+	r.progress = sync.NewCond(&r.mu)
+	r.mu.Unlock()
+
+	pieces := split(left, right, parallelism, merged, len(elements))
+	for _, p := range pieces {
+		go func(p *piece) {
+			p.count = mergeInto(elements[p.offset:p.offset+p.limit], p.left, p.right, r.resolve)
+			close(p.done)
+		}(p)
+	}
+
+	for _, p := range pieces {
+		<-p.done
+		copy(elements[merged:], elements[p.offset:p.offset+p.count])
+		merged += p.count
+
+		r.mu.Lock()
+		r.mx, r.nx = merged, merged
+		r.progress.Broadcast()
+		r.mu.Unlock()
+	}
+
+	r.mu.Lock()
+	r.left = nil
+	r.lx = nil
+	r.right = nil
+	r.rx = nil
+	r.unsorted = nil
+	r.elements = r.elements[0:merged]
+	r.progress.Broadcast()
+	r.progress = nil
+	r.mu.Unlock()
+}
+
+// unmerged answers the part of an arm that has not been consumed by the cursor over the arm.
+func unmerged(arm SortedRange, cursor *mergeCursor) SortedRange {
+	next := cursor.peek()
+	if next == nil {
+		return EmptyRange
+	}
+	_, rest := arm.Partition(next, LessOrder)
+	return rest
+}
+
+// split splits left and right into at most n pieces at matching pivots chosen from the larger
+// arm. Each piece is allocated a region of the slice, starting at offset, large enough for its
+// limit. If the regions do not fit in the slice, which has the specified length, the arms are
+// merged as a single piece whose region is the rest of the slice.
+func split(left SortedRange, right SortedRange, n int, offset int, length int) []*piece {
+	pivots := pivotsOf(right, n)
+	if left.Limit() > right.Limit() || len(pivots) == 0 {
+		pivots = pivotsOf(left, n)
+	}
+
+	whole := &piece{
+		left:   left,
+		right:  right,
+		offset: offset,
+		limit:  length - offset,
+		done:   make(chan struct{}),
+	}
+	pieces := make([]*piece, 0, len(pivots)+1)
+	end := offset
+	for i := 0; i <= len(pivots); i++ {
+		p := &piece{
+			left:   left,
+			right:  right,
+			offset: end,
+			done:   make(chan struct{}),
+		}
+		if i < len(pivots) {
+			p.left, left = left.Partition(pivots[i], LessOrder)
+			p.right, right = right.Partition(pivots[i], LessOrder)
+		}
+		p.limit = p.left.Limit() + p.right.Limit()
+		end += p.limit
+		pieces = append(pieces, p)
+	}
+
+	if end > length {
+		return []*piece{whole}
+	}
+	return pieces
+}
+
+// pivotsOf answers up to n-1 elements of r that divide it into n parts of
+// similar size. Pivots can only be chosen from an immutableRange.
+func pivotsOf(r SortedRange, n int) []Element {
+	t, ok := r.(*immutableRange)
+	if !ok {
+		return nil
+	}
+	pivots := []Element{}
+	for i := 1; i < n; i++ {
+		j := i * len(t.elements) / n
+		if j > 0 && (len(pivots) == 0 || pivots[len(pivots)-1].Less(t.elements[j])) {
+			pivots = append(pivots, t.elements[j])
+		}
+	}
+	return pivots
+}
+
+// mergeInto merges a and b into dst, combining equal elements with the resolver, and
+// answers the number of merged elements.
+func mergeInto(dst []Element, a SortedRange, b SortedRange, resolve Resolver) int {
+	lx := &mergeCursor{underlying: a.Open()}
+	rx := &mergeCursor{underlying: b.Open()}
+	n := 0
+	for {
+		l, r := lx.peek(), rx.peek()
+		var e Element
+		if l == nil && r == nil {
+			return n
+		} else if r == nil || (l != nil && l.Less(r)) {
+			e = lx.next()
+		} else if l == nil || r.Less(l) {
+			e = rx.next()
+		} else {
+			e = resolve.apply(lx.next(), rx.next())
+		}
+		dst[n] = e
+		n++
+	}
+}
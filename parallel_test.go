@@ -0,0 +1,135 @@
+package tsl
+
+import (
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// shuffledRange answers a frozen range of the integers in [0, n) that were added with
+// every fourth element out of order, so that both arms of the merge are large.
+func shuffledRange(n int) SortedRange {
+	u := NewUnsortedRange()
+	late := []Element{}
+	for i := 0; i < n; i++ {
+		if i%4 == 3 {
+			late = append(late, intElement{i})
+		} else {
+			u.Add([]Element{intElement{i}})
+		}
+	}
+	rand.Shuffle(len(late), func(i, j int) {
+		late[i], late[j] = late[j], late[i]
+	})
+	u.Add(late)
+	return u.Freeze()
+}
+
+func Test_MergeInBackground(t *testing.T) {
+	const n = 100000
+	expected := NewElements(sequence(0, n, 1))
+
+	for _, parallelism := range []int{0, 1, 2, 7} {
+		r := shuffledRange(n)
+
+		// a cursor that has advanced the merge before the parallel merge starts
+		before := r.Open()
+		for i := 0; i < 1000; i++ {
+			before.Next()
+		}
+
+		done := MergeInBackground(r, parallelism)
+
+		// cursors that read while the parallel merge is in progress
+		results := make([]Elements, 4)
+		wg := sync.WaitGroup{}
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i] = Elements(AsSlice(r))
+			}(i)
+		}
+		rest := Elements{}
+		for e := before.Next(); e != nil; e = before.Next() {
+			rest = append(rest, e)
+		}
+		wg.Wait()
+		<-done
+
+		if !reflect.DeepEqual(rest, expected[1000:]) {
+			t.Fatalf("parallelism %d: cursor opened before the merge. got: %d elements, expected: %d", parallelism, len(rest), n-1000)
+		}
+		for _, got := range results {
+			if !reflect.DeepEqual(got, expected) {
+				t.Fatalf("parallelism %d: cursor opened during the merge. got: %d elements, expected: %d", parallelism, len(got), n)
+			}
+		}
+		if s := Inspect(r); s.Merged != n || len(s.Children) != 0 {
+			t.Fatalf("parallelism %d: merge should be complete. got: %v", parallelism, s)
+		}
+		if err := checkSortedRangeInvariants(r); err != nil {
+			t.Fatalf("parallelism %d: %v", parallelism, err)
+		}
+	}
+}
+
+func Test_MergeInBackground_Resolver(t *testing.T) {
+	u := NewUnsortedRangeWith(sum)
+	for i := 0; i < 10000; i++ {
+		u.Add(keyed(i, 1))
+	}
+	for i := 9999; i >= 0; i -= 2 {
+		u.Add(keyed(i, 10))
+	}
+	r := u.Freeze()
+	<-MergeInBackground(r, 4)
+
+	got := AsSlice(r)
+	if len(got) != 10000 {
+		t.Fatalf("length. got: %d, expected: %d", len(got), 10000)
+	}
+	for i, e := range got {
+		expected := keyed(i, 1)[0]
+		if i%2 == 1 {
+			expected = keyed(i, 11)[0]
+		}
+		if e != expected {
+			t.Fatalf("element %d. got: %v, expected: %v", i, e, expected)
+		}
+	}
+}
+
+func Test_MergeInBackground_Disjoint(t *testing.T) {
+	a := shuffledRange(1000)
+	b := newImmutableRange(NewElements(sequence(2000, 3000, 1)))
+	r := Merge(a, b)
+	<-MergeInBackground(Visible(r), 3)
+	if s := ShapeOf(r); s.Depth != 2 {
+		t.Fatalf("the merge within the disjoint ranges should be complete. got: %+v", s)
+	}
+	expected := append(NewElements(sequence(0, 1000, 1)), NewElements(sequence(2000, 3000, 1))...)
+	if got := Elements(AsSlice(r)); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got: %v, expected: %v", got, expected)
+	}
+}
+
+func Test_MergeInBackground_KWay(t *testing.T) {
+	const n = 30000
+	r := MergeAll(
+		newImmutableRange(NewElements(sequence(0, n, 3))),
+		newImmutableRange(NewElements(sequence(1, n, 3))),
+		newImmutableRange(NewElements(sequence(2, n, 3))),
+	)
+	if _, ok := r.(*kwayRange); !ok {
+		t.Fatalf("expected a k-way merge. got: %v", Inspect(r))
+	}
+	<-MergeInBackground(r, 2)
+	if s := Inspect(r); s.Merged != n || len(s.Children) != 0 {
+		t.Fatalf("the k-way merge should be complete. got: %v", s)
+	}
+	if got := Elements(AsSlice(r)); !reflect.DeepEqual(got, NewElements(sequence(0, n, 1))) {
+		t.Fatalf("got: %d elements, expected: %d", len(got), n)
+	}
+}
@@ -0,0 +1,95 @@
+package tsl
+
+import (
+	"reflect"
+	"testing"
+)
+
+// sum is a Resolver that adds the seq fields of two equal keyedElements.
+func sum(older, newer Element) Element {
+	return keyedElement{key: older.(keyedElement).key, seq: older.(keyedElement).seq + newer.(keyedElement).seq}
+}
+
+func keyed(pairs ...int) Elements {
+	result := Elements{}
+	for i := 0; i < len(pairs); i += 2 {
+		result = append(result, keyedElement{key: pairs[i], seq: pairs[i+1]})
+	}
+	return result
+}
+
+func Test_MergeWith_Sum(t *testing.T) {
+	a := newImmutableRange(keyed(1, 1, 2, 2, 3, 3))
+	b := newImmutableRange(keyed(2, 20, 3, 30, 4, 40))
+	got := MergeWith(a, b, sum)
+	expected := keyed(1, 1, 2, 22, 3, 33, 4, 40)
+	if !reflect.DeepEqual(Elements(AsSlice(got)), expected) {
+		t.Fatalf("MergeWith. got: %v, expected: %v", AsSlice(got), expected)
+	}
+}
+
+func Test_MergeWith_KeepFirst(t *testing.T) {
+	a := newImmutableRange(keyed(1, 1, 2, 2))
+	b := newImmutableRange(keyed(2, 20, 3, 30))
+	got := MergeWith(a, b, KeepFirst)
+	expected := keyed(1, 1, 2, 2, 3, 30)
+	if !reflect.DeepEqual(Elements(AsSlice(got)), expected) {
+		t.Fatalf("MergeWith. got: %v, expected: %v", AsSlice(got), expected)
+	}
+}
+
+func Test_UnsortedRangeWith_Sum(t *testing.T) {
+	r := NewUnsortedRangeWith(sum)
+	r.Add(keyed(1, 1, 3, 3, 2, 2, 3, 30, 1, 10, 3, 300))
+	got := Elements(AsSlice(r.Freeze()))
+	expected := keyed(1, 11, 2, 2, 3, 333)
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("freeze. got: %v, expected: %v", got, expected)
+	}
+}
+
+// Test_Resolver_IndependentOfSplit checks that the result of resolving the same
+// sequence of additions does not depend on how they were split into ranges.
+func Test_Resolver_IndependentOfSplit(t *testing.T) {
+	input := keyed(5, 1, 1, 2, 3, 4, 5, 8, 1, 16, 2, 32, 5, 64, 3, 128, 4, 256)
+	whole := NewUnsortedRangeWith(sum)
+	whole.Add(input)
+	expected := Elements(AsSlice(whole.Freeze()))
+
+	for split := 1; split < len(input); split++ {
+		a, b := NewUnsortedRangeWith(sum), NewUnsortedRangeWith(sum)
+		a.Add(input[0:split])
+		b.Add(input[split:])
+		got := Elements(AsSlice(MergeWith(a.Freeze(), b.Freeze(), sum)))
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("split at %d. got: %v, expected: %v", split, got, expected)
+		}
+
+		ranges := []SortedRange{}
+		for i := 0; i < len(input); i += split {
+			end := i + split
+			if end > len(input) {
+				end = len(input)
+			}
+			r := NewUnsortedRangeWith(sum)
+			r.Add(input[i:end])
+			ranges = append(ranges, r.Freeze())
+		}
+		got = Elements(AsSlice(MergeAllWith(sum, ranges...)))
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("MergeAllWith in chunks of %d. got: %v, expected: %v", split, got, expected)
+		}
+	}
+}
+
+func Test_LogWith_Sum(t *testing.T) {
+	l := NewLogWith(sum)
+	l.Append(keyed(1, 1, 2, 2))
+	l.Snapshot()
+	l.Append(keyed(2, 20, 1, 10))
+	got := Elements(AsSlice(l.Snapshot()))
+	expected := keyed(1, 11, 2, 22)
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("snapshot. got: %v, expected: %v", got, expected)
+	}
+}
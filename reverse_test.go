@@ -0,0 +1,134 @@
+package tsl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func reversed(elements []Element) Elements {
+	result := make(Elements, len(elements))
+	for i, e := range elements {
+		result[len(elements)-1-i] = e
+	}
+	return result
+}
+
+// checkReverse checks that reverse cursors over r, read with Next and with Fill,
+// answer the elements of r in descending order.
+func checkReverse(t *testing.T, r SortedRange) {
+	if _, ok := r.(ReversibleRange); !ok {
+		t.Fatalf("range should be reversible. got: %T", r)
+	}
+	expected := reversed(AsSlice(r))
+
+	got := Elements{}
+	c := OpenReverse(r)
+	for e := c.Next(); e != nil; e = c.Next() {
+		got = append(got, e)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("reverse Next over %v. got: %v, expected: %v", r, got, expected)
+	}
+
+	got = Elements{}
+	c = OpenReverse(r)
+	buffer := make([]Element, 7)
+	for n := c.Fill(buffer); n > 0; n = c.Fill(buffer) {
+		got = append(got, buffer[0:n]...)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("reverse Fill over %v. got: %v, expected: %v", r, got, expected)
+	}
+}
+
+func Test_Reverse_Immutable(t *testing.T) {
+	checkReverse(t, newImmutableRange(NewElements(sequence(0, 100, 2))))
+	checkReverse(t, EmptyRange)
+}
+
+func Test_Reverse_Disjoint(t *testing.T) {
+	r := MergeAll(
+		newImmutableRange(NewElements(sequence(60, 100, 3))),
+		newImmutableRange(NewElements(sequence(0, 20, 3))),
+		newImmutableRange(NewElements(sequence(30, 50, 3))),
+	)
+	checkReverse(t, r)
+
+	p1, p2 := r.Partition(intElement{40}, LessOrder)
+	checkReverse(t, p1)
+	checkReverse(t, p2)
+}
+
+func Test_Reverse_Mergeable(t *testing.T) {
+	u := NewUnsortedRange()
+	for _, i := range seededRand(t).Perm(100) {
+		u.Add(NewElements([]int{i, i / 2}))
+	}
+	r := u.Freeze()
+	checkReverse(t, r)
+
+	// a reverse cursor opened during the forward merge
+	c := r.Open()
+	for i := 0; i < 10; i++ {
+		c.Next()
+	}
+	checkReverse(t, r)
+
+	// and after the forward merge is complete
+	AsSlice(r)
+	checkReverse(t, r)
+}
+
+func Test_Reverse_Resolver(t *testing.T) {
+	a := newImmutableRange(keyed(1, 1, 2, 2, 3, 3))
+	b := newImmutableRange(keyed(2, 20, 3, 30, 4, 40))
+	c := newImmutableRange(keyed(3, 300, 4, 400, 5, 500))
+
+	for _, r := range []SortedRange{MergeWith(a, b, sum), MergeAllWith(sum, a, b, c), MergeWith(a, b, KeepFirst)} {
+		expected := reversed(AsSlice(r))
+		got := Elements{}
+		cursor := OpenReverse(r)
+		for e := cursor.Next(); e != nil; e = cursor.Next() {
+			got = append(got, e)
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("reverse merge should resolve like forward merge. got: %v, expected: %v", got, expected)
+		}
+	}
+}
+
+func Test_Reverse_MergeAll(t *testing.T) {
+	r := MergeAll(
+		newImmutableRange(NewElements(sequence(0, 100, 3))),
+		newImmutableRange(NewElements(sequence(1, 100, 3))),
+		newImmutableRange(NewElements(sequence(0, 100, 2))),
+	)
+	checkReverse(t, r)
+}
+
+func Test_Reverse_Segment(t *testing.T) {
+	s := writeSegment(t, newImmutableRange(NewElements(sequence(0, 5000, 2))))
+	checkReverse(t, s)
+	p1, p2 := s.Partition(intElement{3001}, LessOrder)
+	checkReverse(t, p1)
+	checkReverse(t, p2)
+}
+
+func Test_Reverse_Visible(t *testing.T) {
+	r := Visible(Merge(newImmutableRange(live(1, 2, 3, 4)), newImmutableRange(tombstones(2, 4))))
+	checkReverse(t, r)
+	if _, ok := OpenReverse(r).(SeekableCursor); ok {
+		t.Fatalf("reverse cursors should not be seekable")
+	}
+}
+
+func Test_Reverse_NotReversible(t *testing.T) {
+	r := struct{ SortedRange }{newImmutableRange(NewElements(sequence(0, 10, 1)))}
+	c := OpenReverse(r)
+	expected := reversed(AsSlice(r))
+	buffer := make([]Element, 20)
+	got := Elements(buffer[0:c.Fill(buffer)])
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("reverse fallback. got: %v, expected: %v", got, expected)
+	}
+}
@@ -0,0 +1,43 @@
+package tsl
+
+// Seekable answers c if it is already a SeekableCursor. Otherwise, it answers
+// a SeekableCursor that seeks by reading and discarding the elements of c.
+func Seekable(c Cursor) SeekableCursor {
+	if s, ok := c.(SeekableCursor); ok {
+		return s
+	}
+	return &scanningCursor{
+		mergeCursor: mergeCursor{
+			underlying: c,
+		},
+	}
+}
+
+// scanningCursor is a SeekableCursor that seeks by scanning an underlying cursor.
+// The lookahead of the embedded mergeCursor holds the element that stopped the scan.
+type scanningCursor struct {
+	mergeCursor
+}
+
+func (c *scanningCursor) Next() Element {
+	return c.next()
+}
+
+func (c *scanningCursor) Fill(buffer []Element) int {
+	filled := 0
+	if len(buffer) > 0 && c.peeked != nil {
+		buffer[0] = c.peeked
+		c.peeked = nil
+		filled = 1
+	}
+	if c.done {
+		return filled
+	}
+	return filled + c.underlying.Fill(buffer[filled:])
+}
+
+func (c *scanningCursor) Seek(e Element) {
+	for p := c.peek(); p != nil && p.Less(e); p = c.peek() {
+		c.next()
+	}
+}
@@ -0,0 +1,144 @@
+package tsl
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// seededRand answers a source of random numbers with a new seed, which is logged so that
+// a failure can be reproduced.
+func seededRand(t testing.TB) *rand.Rand {
+	seed := time.Now().UnixNano()
+	t.Logf("seed: %d", seed)
+	return rand.New(rand.NewSource(seed))
+}
+
+// checkSeek checks that a cursor over r that is seeked to each of the targets
+// answers the elements of r that are not less than the target.
+func checkSeek(t *testing.T, r SortedRange, targets []int) {
+	all := AsSlice(r)
+	for _, k := range targets {
+		e := intElement{k}
+		expected := Elements{}
+		for _, x := range all {
+			if !x.Less(e) {
+				expected = append(expected, x)
+			}
+		}
+
+		c, ok := r.Open().(SeekableCursor)
+		if !ok {
+			t.Fatalf("cursor should be seekable. got: %T", r.Open())
+		}
+		c.Seek(e)
+		buffer := make([]Element, len(all)+1)
+		got := Elements(buffer[0:c.Fill(buffer)])
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("Seek(%v) over %v. got: %v, expected: %v", e, r, got, expected)
+		}
+	}
+}
+
+var seekTargets = []int{-1, 0, 1, 37, 50, 97, 98, 99, 100, 200, 50, 0}
+
+func Test_Seek_Immutable(t *testing.T) {
+	checkSeek(t, newImmutableRange(NewElements(sequence(0, 100, 2))), seekTargets)
+	checkSeek(t, EmptyRange, seekTargets)
+}
+
+func Test_Seek_Disjoint(t *testing.T) {
+	r := MergeAll(
+		newImmutableRange(NewElements(sequence(60, 100, 3))),
+		newImmutableRange(NewElements(sequence(0, 20, 3))),
+		newImmutableRange(NewElements(sequence(30, 50, 3))),
+	)
+	if _, ok := r.(*disjointRanges); !ok {
+		t.Fatalf("expected disjoint ranges. got: %v", r)
+	}
+	checkSeek(t, r, seekTargets)
+}
+
+func Test_Seek_Mergeable(t *testing.T) {
+	r := Merge(newImmutableRange(NewElements(sequence(0, 100, 2))), newImmutableRange(NewElements(sequence(1, 100, 2))))
+	checkSeek(t, r, seekTargets)
+
+	u := NewUnsortedRange()
+	for _, i := range seededRand(t).Perm(100) {
+		u.Add(NewElements([]int{i}))
+	}
+	checkSeek(t, u.Freeze(), seekTargets)
+}
+
+func Test_Seek_PartiallyMerged(t *testing.T) {
+	u := NewUnsortedRange()
+	u.Add(NewElements(sequence(0, 100, 2)))
+	u.Add(NewElements(sequence(1, 100, 2)))
+	r := u.Freeze()
+
+	// advance the merge part of the way
+	c := r.Open()
+	for i := 0; i < 10; i++ {
+		c.Next()
+	}
+	checkSeek(t, r, []int{5, 60})
+	if e := c.Next(); !reflect.DeepEqual(e, intElement{10}) {
+		t.Fatalf("seek should not affect other cursors. got: %v, expected: %v", e, intElement{10})
+	}
+}
+
+func Test_Seek_MergeAll(t *testing.T) {
+	r := MergeAll(
+		newImmutableRange(NewElements(sequence(0, 100, 3))),
+		newImmutableRange(NewElements(sequence(1, 100, 3))),
+		newImmutableRange(NewElements(sequence(2, 100, 3))),
+	)
+	checkSeek(t, r, seekTargets)
+}
+
+func Test_Seek_Segment(t *testing.T) {
+	s := writeSegment(t, newImmutableRange(NewElements(sequence(0, 5000, 2))))
+	checkSeek(t, s, []int{-1, 0, 1, 2047, 2048, 2049, 4000, 4998, 4999, 5000, 10})
+	_, p := s.Partition(intElement{3000}, LessOrder)
+	checkSeek(t, p, []int{0, 3001, 4000, 6000})
+}
+
+func Test_Seek_Visible(t *testing.T) {
+	r := Visible(Merge(newImmutableRange(live(1, 2, 3, 4)), newImmutableRange(tombstones(2, 3))))
+	c := r.Open().(SeekableCursor)
+	c.Seek(deletableElement{key: 2})
+	expected := deletableElement{key: 4}
+	if got := c.Next(); got != expected {
+		t.Fatalf("seek should skip tombstones. got: %v, expected: %v", got, expected)
+	}
+}
+
+func Test_Seek_Forward(t *testing.T) {
+	r := newImmutableRange(NewElements(sequence(0, 10, 1)))
+	c := r.Open().(SeekableCursor)
+	c.Seek(intElement{5})
+	c.Next()
+	c.Seek(intElement{2})
+	expected := intElement{6}
+	if got := c.Next(); got != expected {
+		t.Fatalf("seek should not move backwards. got: %v, expected: %v", got, expected)
+	}
+}
+
+func Test_Seekable(t *testing.T) {
+	r := newImmutableRange(NewElements(sequence(0, 10, 1)))
+	c := Seekable(struct{ Cursor }{r.Open()})
+	c.Next()
+	c.Seek(intElement{4})
+	c.Seek(intElement{3})
+	buffer := make([]Element, 10)
+	got := Elements(buffer[0:c.Fill(buffer)])
+	expected := NewElements(sequence(4, 10, 1))
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("scanning seek. got: %v, expected: %v", got, expected)
+	}
+	if c.Next() != nil {
+		t.Fatalf("cursor should be exhausted")
+	}
+}
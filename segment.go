@@ -0,0 +1,701 @@
+package tsl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// A segment is an immutable file containing the sorted, deduplicated elements
+// of a SortedRange. A segment file has the following layout:
+//
+//	header:  magic "TSLSEG" | version (1 byte) | reserved (1 byte)
+//	blocks:  block* where each block is
+//	         uvarint(count) | (uvarint(len) | encoded element)* | crc32 (4 bytes)
+//	footer:  uvarint(limit) | uvarint(blocks) |
+//	         (uvarint(offset) | uvarint(length) | uvarint(count) | first | last)*
+//	trailer: footer offset (8 bytes) | footer length (4 bytes) | footer crc32 (4 bytes) | magic "TSLSEGND"
+//
+// The first and last elements of each block are recorded in the footer so that
+// a segment can be partitioned by a binary search over the footer index followed
+// by a binary search within a single block.
+//
+// All integers in the trailer are little endian. Checksums are IEEE CRC-32.
+
+var (
+	// ErrBadSegment is returned by OpenSegment if the segment is malformed or corrupt.
+	ErrBadSegment = errors.New("malformed or corrupt segment.")
+)
+
+const (
+	segmentVersion     = 1
+	segmentHeaderSize  = 8
+	segmentTrailerSize = 24
+	segmentBlockSize   = 1024 // the number of elements per block
+)
+
+var (
+	segmentHeaderMagic  = []byte("TSLSEG")
+	segmentTrailerMagic = []byte("TSLSEGND")
+)
+
+// A Codec converts Elements to and from a binary representation. A Codec is
+// used to write Elements to persistent storage and to read them back again.
+type Codec interface {
+	// Encode answers the binary representation of the specified Element.
+	Encode(e Element) ([]byte, error)
+	// Decode answers the Element represented by the specified bytes. Decode
+	// must not retain the slice.
+	Decode(data []byte) (Element, error)
+}
+
+// countingWriter is a writer that knows how many bytes have been
+// written to it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// appendBytes appends a length-prefixed byte slice to buf.
+func appendBytes(buf []byte, data []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(data)))
+	return append(buf, data...)
+}
+
+// appendElement appends a length-prefixed encoding of e to buf.
+func appendElement(buf []byte, e Element, codec Codec) ([]byte, error) {
+	data, err := codec.Encode(e)
+	if err != nil {
+		return buf, err
+	}
+	return appendBytes(buf, data), nil
+}
+
+// WriteSegment writes the contents of the specified SortedRange to w in the segment
+// file format, encoding each element with the specified codec. If the cursor over r stops
+// because of an error, such as a corrupt block of a segment being copied, that error is
+// answered rather than a segment of the elements read before it.
+func WriteSegment(w io.Writer, r SortedRange, codec Codec) error {
+	cw := &countingWriter{w: w}
+
+	header := make([]byte, 0, segmentHeaderSize)
+	header = append(header, segmentHeaderMagic...)
+	header = append(header, segmentVersion, 0)
+	if _, err := cw.Write(header); err != nil {
+		return err
+	}
+
+	footer := []byte{}
+	limit := 0
+	blocks := 0
+	buffer := make([]Element, segmentBlockSize)
+	block := []byte{}
+	c := r.Open()
+	for {
+		n := c.Fill(buffer)
+		if n == 0 {
+			if err := cursorErr(c); err != nil {
+				return err
+			}
+			break
+		}
+		offset := cw.n
+		block = binary.AppendUvarint(block[0:0], uint64(n))
+		for _, e := range buffer[0:n] {
+			var err error
+			if block, err = appendElement(block, e, codec); err != nil {
+				return err
+			}
+		}
+		block = binary.LittleEndian.AppendUint32(block, crc32.ChecksumIEEE(block))
+		if _, err := cw.Write(block); err != nil {
+			return err
+		}
+
+		footer = binary.AppendUvarint(footer, uint64(offset))
+		footer = binary.AppendUvarint(footer, uint64(len(block)))
+		footer = binary.AppendUvarint(footer, uint64(n))
+		var err error
+		if footer, err = appendElement(footer, buffer[0], codec); err != nil {
+			return err
+		}
+		if footer, err = appendElement(footer, buffer[n-1], codec); err != nil {
+			return err
+		}
+		limit += n
+		blocks++
+	}
+
+	footer = append(binary.AppendUvarint(binary.AppendUvarint([]byte{}, uint64(limit)), uint64(blocks)), footer...)
+	footerOffset := cw.n
+	if _, err := cw.Write(footer); err != nil {
+		return err
+	}
+
+	trailer := make([]byte, 0, segmentTrailerSize)
+	trailer = binary.LittleEndian.AppendUint64(trailer, uint64(footerOffset))
+	trailer = binary.LittleEndian.AppendUint32(trailer, uint32(len(footer)))
+	trailer = binary.LittleEndian.AppendUint32(trailer, crc32.ChecksumIEEE(footer))
+	trailer = append(trailer, segmentTrailerMagic...)
+	_, err := cw.Write(trailer)
+	return err
+}
+
+// CreateSegmentFile writes the specified SortedRange to a new segment file with the
+// specified name and syncs it to stable storage.
+func CreateSegmentFile(name string, r SortedRange, codec Codec) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err = WriteSegment(w, r, codec); err == nil {
+		if err = w.Flush(); err == nil {
+			err = f.Sync()
+		}
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// segmentBlock describes one block of a segment.
+type segmentBlock struct {
+	offset int64   // the offset of the block within the segment
+	length int     // the length of the block, including its checksum
+	base   int     // the ordinal of the first element of the block within the segment
+	count  int     // the number of elements in the block
+	first  Element // the first element of the block
+	last   Element // the last element of the block
+}
+
+// segmentFile is the shared state of all the segmentRanges read from a single segment.
+type segmentFile struct {
+	reader io.ReaderAt
+	codec  Codec
+	blocks []segmentBlock
+	limit  int
+
+	mu       sync.Mutex // guards the fields below
+	err      error      // the first error encountered while reading a block
+	cached   int        // the index of the last decoded block
+	elements []Element  // the elements of the last decoded block, or nil
+}
+
+// decodeBytes decodes a length-prefixed byte slice from the front of data, answering the
+// slice and the remainder of data.
+func decodeBytes(data []byte) ([]byte, []byte, error) {
+	n, w := binary.Uvarint(data)
+	if w <= 0 || uint64(len(data)-w) < n {
+		return nil, nil, ErrBadSegment
+	}
+	return data[w : w+int(n)], data[w+int(n):], nil
+}
+
+// decodeUvarint decodes an unsigned varint from the front of data, answering the
+// value and the remainder of data.
+func decodeUvarint(data []byte) (int, []byte, error) {
+	n, w := binary.Uvarint(data)
+	if w <= 0 {
+		return 0, nil, ErrBadSegment
+	}
+	return int(n), data[w:], nil
+}
+
+// decodeElement decodes a length-prefixed element from the front of data, answering
+// the element and the remainder of data.
+func decodeElement(data []byte, codec Codec) (Element, []byte, error) {
+	encoded, data, err := decodeBytes(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	e, err := codec.Decode(encoded)
+	if err != nil {
+		return nil, nil, err
+	}
+	return e, data, nil
+}
+
+// OpenSegment answers a SortedRange for a segment of the specified size that
+// can be read with the specified ReaderAt. Only the footer index of the segment is
+// read into memory. The elements of the segment are read from the ReaderAt, one block at a time,
+// as the range is iterated or partitioned.
+//
+// If an error occurs while reading a block after the segment has been opened, Get answers
+// false and cursors stop early. The error encountered by a cursor is reported by its Err
+// method and the first error encountered while reading the segment is reported by the Err
+// method of every range read from it. Partition, which cannot report an error, panics with
+// an error that wraps it rather than answer ranges that omit the elements of the block.
+func OpenSegment(reader io.ReaderAt, size int64, codec Codec) (SortedRange, error) {
+	if size < segmentHeaderSize+segmentTrailerSize {
+		return nil, ErrBadSegment
+	}
+
+	header := make([]byte, segmentHeaderSize)
+	if _, err := reader.ReadAt(header, 0); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(header[0:len(segmentHeaderMagic)], segmentHeaderMagic) || header[len(segmentHeaderMagic)] != segmentVersion {
+		return nil, ErrBadSegment
+	}
+
+	trailer := make([]byte, segmentTrailerSize)
+	if _, err := reader.ReadAt(trailer, size-segmentTrailerSize); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(trailer[16:], segmentTrailerMagic) {
+		return nil, ErrBadSegment
+	}
+	footerOffset := int64(binary.LittleEndian.Uint64(trailer[0:8]))
+	footerLength := int64(binary.LittleEndian.Uint32(trailer[8:12]))
+	if footerOffset < segmentHeaderSize || footerOffset+footerLength != size-segmentTrailerSize {
+		return nil, ErrBadSegment
+	}
+	footer := make([]byte, footerLength)
+	if _, err := reader.ReadAt(footer, footerOffset); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(footer) != binary.LittleEndian.Uint32(trailer[12:16]) {
+		return nil, ErrBadSegment
+	}
+
+	f := &segmentFile{
+		reader: reader,
+		codec:  codec,
+	}
+
+	var err error
+	var count int
+	data := footer
+	if f.limit, data, err = decodeUvarint(data); err != nil {
+		return nil, err
+	}
+	if count, data, err = decodeUvarint(data); err != nil {
+		return nil, err
+	}
+	base := 0
+	for i := 0; i < count; i++ {
+		b := segmentBlock{base: base}
+		var offset int
+		if offset, data, err = decodeUvarint(data); err != nil {
+			return nil, err
+		}
+		b.offset = int64(offset)
+		if b.length, data, err = decodeUvarint(data); err != nil {
+			return nil, err
+		}
+		if b.count, data, err = decodeUvarint(data); err != nil {
+			return nil, err
+		}
+		if b.first, data, err = decodeElement(data, codec); err != nil {
+			return nil, err
+		}
+		if b.last, data, err = decodeElement(data, codec); err != nil {
+			return nil, err
+		}
+		if b.count == 0 || b.offset < segmentHeaderSize || b.offset+int64(b.length) > footerOffset {
+			return nil, ErrBadSegment
+		}
+		f.blocks = append(f.blocks, b)
+		base += b.count
+	}
+	if base != f.limit || len(data) != 0 {
+		return nil, ErrBadSegment
+	}
+
+	return f.slice(0, f.limit), nil
+}
+
+// block answers the decoded elements of the ith block of the segment. The last block
+// decoded is cached, since partitioning a range may read the same block several times.
+func (f *segmentFile) block(i int) ([]Element, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.elements != nil && f.cached == i {
+		return f.elements, nil
+	}
+	elements, err := f.read(i)
+	if err != nil {
+		if f.err == nil {
+			f.err = err
+		}
+		return nil, err
+	}
+	f.cached, f.elements = i, elements
+	return elements, nil
+}
+
+// read reads and decodes the ith block of the segment.
+func (f *segmentFile) read(i int) ([]Element, error) {
+	b := f.blocks[i]
+	data := make([]byte, b.length)
+	if _, err := f.reader.ReadAt(data, b.offset); err != nil {
+		return nil, err
+	}
+	if len(data) < 4 {
+		return nil, ErrBadSegment
+	}
+	body, checksum := data[0:len(data)-4], data[len(data)-4:]
+	if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(checksum) {
+		return nil, ErrBadSegment
+	}
+	count, body, err := decodeUvarint(body)
+	if err != nil {
+		return nil, err
+	}
+	if count != b.count {
+		return nil, ErrBadSegment
+	}
+	elements := make([]Element, count)
+	for j := range elements {
+		if elements[j], body, err = decodeElement(body, f.codec); err != nil {
+			return nil, err
+		}
+	}
+	return elements, nil
+}
+
+// blockOf answers the index of the block that contains the element with the specified ordinal.
+func (f *segmentFile) blockOf(ordinal int) int {
+	return sort.Search(len(f.blocks), func(i int) bool {
+		return f.blocks[i].base+f.blocks[i].count > ordinal
+	})
+}
+
+// Err answers the first error encountered while reading a block of the segment, if any.
+func (f *segmentFile) Err() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.err
+}
+
+// element answers the element with the specified ordinal, reading the
+// block that contains it only if the ordinal is not at a block boundary.
+// element answers nil if the block cannot be read.
+func (f *segmentFile) element(ordinal int) Element {
+	i := f.blockOf(ordinal)
+	b := f.blocks[i]
+	switch ordinal - b.base {
+	case 0:
+		return b.first
+	case b.count - 1:
+		return b.last
+	default:
+		elements, err := f.block(i)
+		if err != nil {
+			return nil
+		}
+		return elements[ordinal-b.base]
+	}
+}
+
+// slice answers a segmentRange containing the elements with ordinals in [lo, hi), or
+// an empty range if the blocks containing its first or last element cannot be read.
+func (f *segmentFile) slice(lo int, hi int) SortedRange {
+	if lo >= hi {
+		return emptyRange
+	}
+	first := f.element(lo)
+	if first == nil {
+		return emptyRange
+	}
+	last := f.element(hi - 1)
+	if last == nil {
+		return emptyRange
+	}
+	return &segmentRange{
+		file:  f,
+		lo:    lo,
+		hi:    hi,
+		first: first,
+		last:  last,
+	}
+}
+
+// segmentRange is a SortedRange over the elements of a segment whose ordinals are in [lo, hi).
+type segmentRange struct {
+	file  *segmentFile
+	lo    int
+	hi    int
+	first Element
+	last  Element
+}
+
+func (r *segmentRange) First() Element {
+	return r.first
+}
+
+func (r *segmentRange) Last() Element {
+	return r.last
+}
+
+func (r *segmentRange) Limit() int {
+	return r.hi - r.lo
+}
+
+// Count answers the number of elements in the range, which is known exactly
+// from the footer index.
+func (r *segmentRange) Count() int {
+	return r.hi - r.lo
+}
+
+// Get uses the footer index to find the only block that might contain key and then
+// reads that block, unless key is the first or last element of the block.
+func (r *segmentRange) Get(key Element) (Element, bool) {
+	if key.Less(r.first) || r.last.Less(key) {
+		return nil, false
+	}
+	f := r.file
+	lo := f.blockOf(r.lo)
+	hi := f.blockOf(r.hi - 1)
+	i := lo + sort.Search(hi-lo+1, func(i int) bool {
+		return !f.blocks[lo+i].last.Less(key)
+	})
+
+	b := f.blocks[i]
+	if !key.Less(b.last) {
+		return b.last, true
+	} else if !b.first.Less(key) {
+		if !key.Less(b.first) {
+			return b.first, true
+		}
+		return nil, false
+	}
+	elements, err := f.block(i)
+	if err != nil {
+		return nil, false
+	}
+	return get(elements, key)
+}
+
+// Open answers a cursor that streams the elements of the range from the segment,
+// one block at a time.
+func (r *segmentRange) Open() Cursor {
+	return &segmentCursor{
+		file:  r.file,
+		block: r.file.blockOf(r.lo) - 1,
+		next:  r.lo,
+		hi:    r.hi,
+	}
+}
+
+// OpenReverse answers a cursor that streams the elements of the range from the
+// segment in descending order, one block at a time.
+func (r *segmentRange) OpenReverse() Cursor {
+	return &segmentReverseCursor{
+		file: r.file,
+		next: r.hi - 1,
+		lo:   r.lo,
+	}
+}
+
+// Partition uses a binary search over the footer index to find the block that contains
+// the partition boundary, and then a binary search within that block to find the boundary itself.
+func (r *segmentRange) Partition(e Element, o Order) (SortedRange, SortedRange) {
+	f := r.file
+	lo := f.blockOf(r.lo)
+	hi := f.blockOf(r.hi - 1)
+	i := lo + sort.Search(hi-lo+1, func(i int) bool {
+		return !o(f.blocks[lo+i].last, e)
+	})
+
+	var split int
+	if i > hi {
+		split = r.hi
+	} else if !o(f.blocks[i].first, e) {
+		split = f.blocks[i].base
+	} else {
+		elements, err := f.block(i)
+		if err != nil {
+			// there is no way to report the error, and answering ranges that omit the
+			// block would silently drop its elements from every merge of the segment.
+			panic(fmt.Errorf("tsl: partition of segment block %d: %w", i, err))
+		}
+		split = f.blocks[i].base + sort.Search(len(elements), func(j int) bool {
+			return !o(elements[j], e)
+		})
+	}
+
+	if split < r.lo {
+		split = r.lo
+	} else if split > r.hi {
+		split = r.hi
+	}
+
+	return f.slice(r.lo, split), f.slice(split, r.hi)
+}
+
+// Err answers the first error encountered while reading a block of the segment, if any.
+func (r *segmentRange) Err() error {
+	return r.file.Err()
+}
+
+func (r *segmentRange) String() string {
+	return fmt.Sprintf("segmentRange{first: %v, last: %v, lo: %d, hi: %d}", r.first, r.last, r.lo, r.hi)
+}
+
+// segmentCursor iterates over the elements of a segment with ordinals in [next, hi),
+// reading one block at a time.
+type segmentCursor struct {
+	file     *segmentFile
+	block    int       // the index of the buffered block
+	elements []Element // the elements of the buffered block
+	next     int       // the ordinal of the next element
+	hi       int       // the ordinal of the end of the range
+	err      error     // the first error encountered
+}
+
+// load ensures that the block containing the next element is buffered.
+func (c *segmentCursor) load() bool {
+	if c.err != nil || c.next >= c.hi {
+		return false
+	}
+	if c.elements != nil && c.next < c.file.blocks[c.block].base+len(c.elements) {
+		return true
+	}
+	c.block = c.file.blockOf(c.next)
+	c.elements, c.err = c.file.block(c.block)
+	return c.err == nil
+}
+
+func (c *segmentCursor) Next() Element {
+	if !c.load() {
+		return nil
+	}
+	e := c.elements[c.next-c.file.blocks[c.block].base]
+	c.next++
+	return e
+}
+
+func (c *segmentCursor) Fill(buffer []Element) int {
+	filled := 0
+	for filled < len(buffer) && c.load() {
+		base := c.file.blocks[c.block].base
+		end := base + len(c.elements)
+		if end > c.hi {
+			end = c.hi
+		}
+		n := copy(buffer[filled:], c.elements[c.next-base:end-base])
+		filled += n
+		c.next += n
+	}
+	return filled
+}
+
+// Seek uses the footer index to skip the blocks whose elements all sort before e, and
+// then a binary search to find e within the first block that is not skipped.
+func (c *segmentCursor) Seek(e Element) {
+	if c.err != nil || c.next >= c.hi {
+		return
+	}
+	f := c.file
+	lo := f.blockOf(c.next)
+	hi := f.blockOf(c.hi - 1)
+	i := lo + sort.Search(hi-lo+1, func(i int) bool {
+		return !f.blocks[lo+i].last.Less(e)
+	})
+
+	target := c.hi
+	if i <= hi {
+		if !f.blocks[i].first.Less(e) {
+			target = f.blocks[i].base
+		} else {
+			if c.elements == nil || c.block != i {
+				c.block = i
+				if c.elements, c.err = f.block(i); c.err != nil {
+					return
+				}
+			}
+			target = f.blocks[i].base + seek(c.elements, 0, len(c.elements), e)
+		}
+	}
+
+	if target > c.hi {
+		target = c.hi
+	}
+	if target > c.next {
+		c.next = target
+	}
+}
+
+// Err answers the first error encountered by the cursor, if any.
+func (c *segmentCursor) Err() error {
+	return c.err
+}
+
+// Close releases the buffered block and ends the iteration, answering the first error
+// encountered by the cursor, if any.
+func (c *segmentCursor) Close() error {
+	c.elements = nil
+	c.next = c.hi
+	return c.err
+}
+
+// segmentReverseCursor iterates backwards over the elements of a segment with
+// ordinals in [lo, next], reading one block at a time.
+type segmentReverseCursor struct {
+	file     *segmentFile
+	block    int       // the index of the buffered block
+	elements []Element // the elements of the buffered block
+	next     int       // the ordinal of the next element
+	lo       int       // the ordinal of the start of the range
+	err      error     // the first error encountered
+}
+
+// load ensures that the block containing the next element is buffered.
+func (c *segmentReverseCursor) load() bool {
+	if c.err != nil || c.next < c.lo {
+		return false
+	}
+	if c.elements != nil && c.next >= c.file.blocks[c.block].base {
+		return true
+	}
+	c.block = c.file.blockOf(c.next)
+	c.elements, c.err = c.file.block(c.block)
+	return c.err == nil
+}
+
+func (c *segmentReverseCursor) Next() Element {
+	if !c.load() {
+		return nil
+	}
+	e := c.elements[c.next-c.file.blocks[c.block].base]
+	c.next--
+	return e
+}
+
+func (c *segmentReverseCursor) Fill(buffer []Element) int {
+	for i := range buffer {
+		if buffer[i] = c.Next(); buffer[i] == nil {
+			return i
+		}
+	}
+	return len(buffer)
+}
+
+// Err answers the first error encountered by the cursor, if any.
+func (c *segmentReverseCursor) Err() error {
+	return c.err
+}
+
+// Close releases the buffered block and ends the iteration, answering the first error
+// encountered by the cursor, if any.
+func (c *segmentReverseCursor) Close() error {
+	c.elements = nil
+	c.next = c.lo - 1
+	return c.err
+}
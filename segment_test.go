@@ -0,0 +1,239 @@
+package tsl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// intCodec is a Codec for intElements.
+type intCodec struct{}
+
+func (intCodec) Encode(e Element) ([]byte, error) {
+	return binary.AppendVarint(nil, int64(e.(intElement).value)), nil
+}
+
+func (intCodec) Decode(data []byte) (Element, error) {
+	v, n := binary.Varint(data)
+	if n <= 0 {
+		return nil, errors.New("bad varint")
+	}
+	return intElement{int(v)}, nil
+}
+
+func sequence(lo, hi, step int) []int {
+	result := []int{}
+	for i := lo; i < hi; i += step {
+		result = append(result, i)
+	}
+	return result
+}
+
+func writeSegment(t *testing.T, r SortedRange) SortedRange {
+	buf := &bytes.Buffer{}
+	if err := WriteSegment(buf, r, intCodec{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s, err := OpenSegment(bytes.NewReader(buf.Bytes()), int64(buf.Len()), intCodec{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return s
+}
+
+func Test_Segment_Empty(t *testing.T) {
+	got := writeSegment(t, EmptyRange)
+	if err := checkSortedRangeInvariants(got); err != nil {
+		t.Fatalf("got: %v. %v", got, err)
+	}
+	if got.Limit() != 0 {
+		t.Fatalf("empty segment. got: %v, expected: %v", got, EmptyRange)
+	}
+}
+
+func Test_Segment_RoundTrip(t *testing.T) {
+	d := NewElements(sequence(0, 3*segmentBlockSize+17, 2))
+	got := writeSegment(t, newImmutableRange(d))
+	if err := checkSortedRangeInvariants(got); err != nil {
+		t.Fatalf("got: %v. %v", got, err)
+	}
+	if !reflect.DeepEqual(Elements(AsSlice(got)), d) {
+		t.Fatalf("round trip failed. got: %v, expected: %v", AsSlice(got), d)
+	}
+}
+
+func Test_Segment_Partition(t *testing.T) {
+	d := NewElements(sequence(0, 3*segmentBlockSize+17, 2))
+	expected := newImmutableRange(d)
+	s := writeSegment(t, expected)
+
+	for _, pivot := range []int{-1, 0, 1, 2, 2047, 2048, 2049, 4000, 6000, 6200, 7000} {
+		for _, o := range []Order{LessOrder, LessOrEqualOrder} {
+			p1, p2 := s.Partition(intElement{pivot}, o)
+			e1, e2 := expected.Partition(intElement{pivot}, o)
+			if !reflect.DeepEqual(AsSlice(p1), AsSlice(e1)) || !reflect.DeepEqual(AsSlice(p2), AsSlice(e2)) {
+				t.Fatalf("partition at %d failed. got: %v, %v, expected: %v, %v", pivot, p1, p2, e1, e2)
+			}
+			if err := checkSortedRangeInvariants(p1); err != nil {
+				t.Fatalf("got: %v. %v", p1, err)
+			}
+			if err := checkSortedRangeInvariants(p2); err != nil {
+				t.Fatalf("got: %v. %v", p2, err)
+			}
+
+			q1, q2 := p2.Partition(intElement{pivot + 1000}, o)
+			f1, f2 := e2.Partition(intElement{pivot + 1000}, o)
+			if !reflect.DeepEqual(AsSlice(q1), AsSlice(f1)) || !reflect.DeepEqual(AsSlice(q2), AsSlice(f2)) {
+				t.Fatalf("second partition at %d failed. got: %v, %v, expected: %v, %v", pivot+1000, q1, q2, f1, f2)
+			}
+		}
+	}
+}
+
+func Test_Segment_Merge(t *testing.T) {
+	a := writeSegment(t, newImmutableRange(NewElements(sequence(0, 3000, 2))))
+	b := writeSegment(t, newImmutableRange(NewElements(sequence(1, 3000, 2))))
+	got := Merge(a, b)
+	expected := NewElements(sequence(0, 3000, 1))
+	if !reflect.DeepEqual(Elements(AsSlice(got)), expected) {
+		t.Fatalf("merge failed. got: %v, expected: %v", AsSlice(got), expected)
+	}
+}
+
+func Test_Segment_Corrupt(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := WriteSegment(buf, newImmutableRange(NewElements(sequence(0, 100, 1))), intCodec{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data := buf.Bytes()
+	data[len(data)-segmentTrailerSize-1] ^= 0xff
+	if _, err := OpenSegment(bytes.NewReader(data), int64(len(data)), intCodec{}); err != ErrBadSegment {
+		t.Fatalf("corrupt footer. got: %v, expected: %v", err, ErrBadSegment)
+	}
+	data[len(data)-segmentTrailerSize-1] ^= 0xff
+
+	data[segmentHeaderSize+3] ^= 0xff
+	s, err := OpenSegment(bytes.NewReader(data), int64(len(data)), intCodec{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c := s.Open()
+	if e := c.Next(); e != nil {
+		t.Fatalf("corrupt block. got: %v, expected: nil", e)
+	}
+	if err := c.(*segmentCursor).Err(); err != ErrBadSegment {
+		t.Fatalf("corrupt block. got: %v, expected: %v", err, ErrBadSegment)
+	}
+}
+
+func Test_Segment_File(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "segment")
+	d := NewElements(sequence(0, 100, 1))
+	if err := CreateSegmentFile(name, newImmutableRange(d), intCodec{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s, err := OpenSegment(f, fi.Size(), intCodec{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(Elements(AsSlice(s)), d) {
+		t.Fatalf("round trip failed. got: %v, expected: %v", AsSlice(s), d)
+	}
+}
+
+// countingReaderAt counts the reads of a ReaderAt.
+type countingReaderAt struct {
+	r     io.ReaderAt
+	reads int
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	c.reads++
+	return c.r.ReadAt(p, off)
+}
+
+func Test_Segment_CorruptPartition(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := WriteSegment(buf, newImmutableRange(NewElements(sequence(0, 3000, 1))), intCodec{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data := buf.Bytes()
+	s, err := OpenSegment(bytes.NewReader(data), int64(len(data)), intCodec{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	offset := s.(*segmentRange).file.blocks[1].offset
+	data[offset+3] ^= 0xff
+
+	func() {
+		defer func() {
+			if err, ok := recover().(error); !ok || !errors.Is(err, ErrBadSegment) {
+				t.Fatalf("partition of a corrupt block. got: %v, expected: a panic with %v", err, ErrBadSegment)
+			}
+		}()
+		p1, p2 := s.Partition(intElement{1500}, LessOrder)
+		t.Fatalf("partition of a corrupt block. got: %v, %v, expected: a panic", p1, p2)
+	}()
+	if e, ok := Get(s, intElement{1500}); ok {
+		t.Fatalf("get from a corrupt block. got: %v, expected: none", e)
+	}
+	if err := s.(*segmentRange).Err(); err != ErrBadSegment {
+		t.Fatalf("err. got: %v, expected: %v", err, ErrBadSegment)
+	}
+	if e, ok := Get(s, intElement{2500}); !ok || e != (intElement{2500}) {
+		t.Fatalf("get from an intact block. got: %v, %v, expected: 2500, true", e, ok)
+	}
+}
+
+func Test_Segment_WriteCorrupt(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := WriteSegment(buf, newImmutableRange(NewElements(sequence(0, 3*segmentBlockSize, 1))), intCodec{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data := buf.Bytes()
+	s, err := OpenSegment(bytes.NewReader(data), int64(len(data)), intCodec{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	offset := s.(*segmentRange).file.blocks[1].offset
+	data[offset+3] ^= 0xff
+
+	if err := WriteSegment(&bytes.Buffer{}, s, intCodec{}); err != ErrBadSegment {
+		t.Fatalf("copy of a corrupt segment. got: %v, expected: %v", err, ErrBadSegment)
+	}
+}
+
+func Test_Segment_BlockCache(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := WriteSegment(buf, newImmutableRange(NewElements(sequence(0, 3000, 1))), intCodec{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	reader := &countingReaderAt{r: bytes.NewReader(buf.Bytes())}
+	s, err := OpenSegment(reader, int64(buf.Len()), intCodec{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	reader.reads = 0
+	p1, p2 := s.Partition(intElement{1500}, LessOrder)
+	if p1.Last() != (intElement{1499}) || p2.First() != (intElement{1500}) {
+		t.Fatalf("partition. got: %v, %v", p1, p2)
+	}
+	if reader.reads != 1 {
+		t.Fatalf("partition within a block should read it once. got: %d reads", reader.reads)
+	}
+}
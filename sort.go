@@ -0,0 +1,158 @@
+package tsl
+
+// minRun is the minimum length of a run. Shorter runs are extended to this
+// length with an insertion sort before they are merged.
+const minRun = 32
+
+// run describes a sorted sub-slice, elements[start:start+length].
+type run struct {
+	start  int
+	length int
+}
+
+// runSort is a stable sort that takes advantage of the ascending and descending runs
+// that already exist in its input. It follows the approach of TimSort: the input is
+// split into maximal runs, strictly descending runs are reversed, short runs are
+// extended with an insertion sort and the runs are then merged pairwise, using a stack
+// whose invariants keep the merges balanced.
+//
+// The cost of sorting input that consists of a few long runs, such as the elements of a
+// timeseries that arrive out of order, is close to O(n) rather than O(n.log(n)).
+func runSort(elements []Element) {
+	n := len(elements)
+	if n < 2 {
+		return
+	}
+
+	tmp := []Element{}
+	stack := []run{}
+	for lo := 0; lo < n; {
+		hi := nextRun(elements, lo)
+		if hi-lo < minRun {
+			forced := lo + minRun
+			if forced > n {
+				forced = n
+			}
+			insertionSort(elements[lo:forced], hi-lo)
+			hi = forced
+		}
+		stack = append(stack, run{start: lo, length: hi - lo})
+		lo = hi
+
+		// restore the invariants: for each triple of runs A, B, C on top of the
+		// stack, A > B + C and B > C.
+		for len(stack) > 1 {
+			k := len(stack) - 2
+			if k > 0 && stack[k-1].length <= stack[k].length+stack[k+1].length {
+				if stack[k-1].length < stack[k+1].length {
+					k--
+				}
+			} else if stack[k].length > stack[k+1].length {
+				break
+			}
+			tmp = mergeRuns(elements, stack[k], stack[k+1], tmp)
+			stack[k].length += stack[k+1].length
+			stack = append(stack[0:k+1], stack[k+2:]...)
+		}
+	}
+
+	for len(stack) > 1 {
+		k := len(stack) - 2
+		tmp = mergeRuns(elements, stack[k], stack[k+1], tmp)
+		stack[k].length += stack[k+1].length
+		stack = stack[0 : k+1]
+	}
+}
+
+// nextRun answers the end of the run that starts at lo. A strictly
+// descending run is reversed so that it becomes an ascending run. Runs that
+// are not strictly descending are not reversed since doing so would reorder
+// equal elements.
+func nextRun(elements []Element, lo int) int {
+	hi := lo + 1
+	if hi == len(elements) {
+		return hi
+	}
+	if elements[hi].Less(elements[lo]) {
+		for hi++; hi < len(elements) && elements[hi].Less(elements[hi-1]); hi++ {
+		}
+		for i, j := lo, hi-1; i < j; i, j = i+1, j-1 {
+			elements[i], elements[j] = elements[j], elements[i]
+		}
+	} else {
+		for hi++; hi < len(elements) && !elements[hi].Less(elements[hi-1]); hi++ {
+		}
+	}
+	return hi
+}
+
+// insertionSort sorts elements given that elements[0:sorted] is already sorted. Each
+// element is inserted by scanning backwards from the end of the sorted prefix, so
+// an element that is only slightly out of order costs only a few comparisons.
+func insertionSort(elements []Element, sorted int) {
+	for i := sorted; i < len(elements); i++ {
+		e := elements[i]
+		j := i
+		for j > 0 && e.Less(elements[j-1]) {
+			elements[j] = elements[j-1]
+			j--
+		}
+		elements[j] = e
+	}
+}
+
+// mergeRuns stably merges the adjacent runs a and b, answering the (possibly grown)
+// temporary buffer so that it can be reused by subsequent merges.
+//
+// Only the overlapping parts of the runs are merged: the prefix of a that sorts
+// before or with the first element of b and the suffix of b that sorts after the
+// last element of a are already in their final positions.
+func mergeRuns(elements []Element, a run, b run, tmp []Element) []Element {
+	left := elements[a.start : a.start+a.length]
+	right := elements[b.start : b.start+b.length]
+
+	if !right[0].Less(left[len(left)-1]) {
+		return tmp
+	}
+
+	// skip the prefix of left whose elements are <= right[0].
+	lo, hi := 0, len(left)
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		if right[0].Less(left[mid]) {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	left = left[lo:]
+
+	// skip the suffix of right whose elements are >= left[len(left)-1].
+	last := left[len(left)-1]
+	lo, hi = 0, len(right)
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		if right[mid].Less(last) {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	right = right[0:lo]
+
+	tmp = append(tmp[0:0], left...)
+	out := left[0 : len(left)+len(right)]
+	i, j, k := 0, 0, 0
+	for i < len(tmp) && j < len(right) {
+		if right[j].Less(tmp[i]) {
+			out[k] = right[j]
+			j++
+		} else {
+			out[k] = tmp[i]
+			i++
+		}
+		k++
+	}
+	copy(out[k:], tmp[i:])
+	return tmp
+}
@@ -0,0 +1,118 @@
+package tsl
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// keyedElement is an Element that is ordered by key alone, so that the
+// order of equal elements can be used to check the stability of a sort.
+type keyedElement struct {
+	key int
+	seq int
+}
+
+func (k keyedElement) Less(e Element) bool {
+	return k.key < e.(keyedElement).key
+}
+
+func keyedElements(keys []int) Elements {
+	result := make(Elements, len(keys))
+	for i, k := range keys {
+		result[i] = keyedElement{key: k, seq: i}
+	}
+	return result
+}
+
+func checkRunSort(t *testing.T, name string, keys []int) {
+	got := keyedElements(keys)
+	expected := keyedElements(keys)
+	runSort(got)
+	sort.Stable(expected)
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("%s: runSort differs from sort.Stable. got: %v, expected: %v", name, got, expected)
+	}
+}
+
+func Test_RunSort(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, n := range []int{0, 1, 2, 3, 31, 32, 33, 100, 1000, 5000} {
+		random := make([]int, n)
+		ascending := make([]int, n)
+		descending := make([]int, n)
+		sawtooth := make([]int, n)
+		duplicates := make([]int, n)
+		for i := 0; i < n; i++ {
+			random[i] = rng.Intn(n + 1)
+			ascending[i] = i
+			descending[i] = n - i
+			sawtooth[i] = i % 97
+			duplicates[i] = rng.Intn(4)
+		}
+		checkRunSort(t, "random", random)
+		checkRunSort(t, "ascending", ascending)
+		checkRunSort(t, "descending", descending)
+		checkRunSort(t, "sawtooth", sawtooth)
+		checkRunSort(t, "duplicates", duplicates)
+		checkRunSort(t, "access log", accessLogTimestamps(rng, n))
+	}
+}
+
+// accessLogTimestamps answers the start times, in milliseconds, of n requests in the
+// order in which they would be written to an access log. Requests arrive at a steady
+// rate and are logged when they end, so the log is sorted by end time. Most requests
+// complete quickly but the durations have a long tail, so the start times are almost,
+// but not completely, sorted.
+func accessLogTimestamps(rng *rand.Rand, n int) []int {
+	type request struct {
+		start int
+		end   int
+	}
+	requests := make([]request, n)
+	now := 0.0
+	for i := range requests {
+		now += rng.ExpFloat64() * 50
+		duration := math.Exp(rng.NormFloat64()*1.5 + 3)
+		requests[i] = request{start: int(now), end: int(now + duration)}
+	}
+	sort.SliceStable(requests, func(i, j int) bool {
+		return requests[i].end < requests[j].end
+	})
+	result := make([]int, n)
+	for i, r := range requests {
+		result[i] = r.start
+	}
+	return result
+}
+
+// accessLogBuffer answers the out-of-order elements that a mutableRange accumulates
+// when n access log timestamps are added to it.
+func accessLogBuffer(n int) []Element {
+	r := &mutableRange{}
+	r.Add(NewElements(accessLogTimestamps(rand.New(rand.NewSource(1)), n)))
+	return r.unsorted.elements
+}
+
+func benchmarkSort(b *testing.B, sorter func([]Element)) {
+	input := accessLogBuffer(1000000)
+	elements := make([]Element, len(input))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		copy(elements, input)
+		sorter(elements)
+	}
+	b.ReportMetric(float64(len(input)), "elements/op")
+}
+
+func Benchmark_RunSort_AccessLog(b *testing.B) {
+	benchmarkSort(b, runSort)
+}
+
+func Benchmark_SortStable_AccessLog(b *testing.B) {
+	benchmarkSort(b, func(elements []Element) {
+		sort.Stable(Elements(elements))
+	})
+}
@@ -0,0 +1,233 @@
+package tsl
+
+import (
+	"fmt"
+	"sync"
+)
+
+// isTombstone answers true if e is a Tombstone that marks a deletion.
+func isTombstone(e Element) bool {
+	t, ok := e.(Tombstone)
+	return ok && t.IsTombstone()
+}
+
+// visibleRange is a view of a SortedRange that hides the tombstones it contains.
+// The bounds of the underlying range are used unless they are tombstones, in which
+// case the first and last visible elements are found lazily by searching from either
+// end of the range. The number of visible elements is found lazily, with a single scan
+// of the underlying range, only if it is asked for, or by the first cursor that iterates
+// over the whole range.
+type visibleRange struct {
+	underlying SortedRange
+	firstOnce  sync.Once
+	first      Element
+	lastOnce   sync.Once
+	last       Element
+	mu         sync.Mutex // guards counted and count
+	counted    bool       // true once the number of visible elements is known
+	count      int        // the number of visible elements
+}
+
+// Visible answers a view of the specified SortedRange whose cursors skip tombstones.
+// The view is intended for readers; ranges that are to be merged with older data should
+// be merged before they are wrapped, otherwise the tombstones cannot suppress the older
+// elements they delete.
+func Visible(r SortedRange) SortedRange {
+	if v, ok := r.(*visibleRange); ok {
+		return v
+	}
+	return &visibleRange{underlying: r}
+}
+
+// firstVisible answers the first element of c that is not a tombstone, or nil if there is none.
+func firstVisible(c Cursor) Element {
+	defer closeCursor(c)
+	for e := c.Next(); e != nil; e = c.Next() {
+		if !isTombstone(e) {
+			return e
+		}
+	}
+	return nil
+}
+
+func (r *visibleRange) First() Element {
+	r.firstOnce.Do(func() {
+		if r.first = r.underlying.First(); r.first != nil && isTombstone(r.first) {
+			r.first = firstVisible(r.underlying.Open())
+		}
+	})
+	return r.first
+}
+
+func (r *visibleRange) Last() Element {
+	r.lastOnce.Do(func() {
+		if r.last = r.underlying.Last(); r.last != nil && isTombstone(r.last) {
+			r.last = firstVisible(OpenReverse(r.underlying))
+		}
+	})
+	return r.last
+}
+
+// visible answers the number of visible elements, if it is known.
+func (r *visibleRange) visible() (int, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.count, r.counted
+}
+
+// setCount records the number of visible elements, once a cursor has counted them.
+func (r *visibleRange) setCount(count int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.count, r.counted = count, true
+}
+
+// Limit answers the number of visible elements if it is known and otherwise the limit
+// of the underlying range, unless every element of the underlying range is a tombstone,
+// in which case it answers 0.
+func (r *visibleRange) Limit() int {
+	if count, ok := r.visible(); ok {
+		return count
+	}
+	if r.First() == nil {
+		return 0
+	}
+	return r.underlying.Limit()
+}
+
+// Get answers false if the element equal to key is a tombstone.
+func (r *visibleRange) Get(key Element) (Element, bool) {
+	e, ok := Get(r.underlying, key)
+	if !ok || isTombstone(e) {
+		return nil, false
+	}
+	return e, true
+}
+
+// Count answers the number of visible elements, scanning the range if they have not
+// yet been counted.
+func (r *visibleRange) Count() int {
+	if count, ok := r.visible(); ok {
+		return count
+	}
+	c := r.Open()
+	defer closeCursor(c)
+	for e := c.Next(); e != nil; e = c.Next() {
+	}
+	count, _ := r.visible()
+	return count
+}
+
+func (r *visibleRange) Open() Cursor {
+	return &visibleSeekableCursor{
+		visibleCursor: visibleCursor{
+			underlying: r.underlying.Open(),
+			owner:      r,
+		},
+	}
+}
+
+func (r *visibleRange) OpenReverse() Cursor {
+	return &visibleCursor{
+		underlying: OpenReverse(r.underlying),
+		owner:      r,
+	}
+}
+
+func (r *visibleRange) Partition(e Element, o Order) (SortedRange, SortedRange) {
+	p1, p2 := r.underlying.Partition(e, o)
+	return Visible(p1), Visible(p2)
+}
+
+func (r *visibleRange) String() string {
+	return fmt.Sprintf("visibleRange{%v}", r.underlying)
+}
+
+// visibleCursor is a cursor that skips the tombstones of an underlying cursor. A cursor
+// that iterates over every element of its range records the number of visible elements
+// in the range.
+type visibleCursor struct {
+	underlying Cursor
+	owner      *visibleRange // the range to count, or nil if the cursor has skipped elements
+	count      int           // the number of visible elements answered
+}
+
+// done records the number of visible elements in the range once the underlying cursor
+// is exhausted.
+func (c *visibleCursor) done() {
+	if c.owner != nil && cursorErr(c.underlying) == nil {
+		c.owner.setCount(c.count)
+	}
+	c.owner = nil
+}
+
+func (c *visibleCursor) Next() Element {
+	for {
+		e := c.underlying.Next()
+		if e == nil {
+			c.done()
+			return nil
+		}
+		if !isTombstone(e) {
+			c.count++
+			return e
+		}
+	}
+}
+
+func (c *visibleCursor) Fill(buffer []Element) int {
+	next := 0
+	for next < len(buffer) {
+		filled := c.underlying.Fill(buffer[next:])
+		if filled == 0 {
+			c.count += next
+			c.done()
+			return next
+		}
+		for _, e := range buffer[next : next+filled] {
+			if !isTombstone(e) {
+				buffer[next] = e
+				next++
+			}
+		}
+	}
+	c.count += next
+	return next
+}
+
+func (c *visibleCursor) Err() error {
+	return cursorErr(c.underlying)
+}
+
+func (c *visibleCursor) Close() error {
+	return closeCursor(c.underlying)
+}
+
+// visibleSeekableCursor is a visibleCursor over a cursor that iterates in ascending
+// order and so can be seeked.
+type visibleSeekableCursor struct {
+	visibleCursor
+}
+
+func (c *visibleSeekableCursor) Seek(e Element) {
+	c.owner = nil
+	s := Seekable(c.underlying)
+	s.Seek(e)
+	c.underlying = s
+}
+
+// DeleteRange answers a SortedRange that contains the elements of the specified range
+// except those in [from, to). The result shares storage with the specified range.
+func DeleteRange(r SortedRange, from Element, to Element) SortedRange {
+	older, rest := r.Partition(from, LessOrder)
+	_, newer := rest.Partition(to, LessOrder)
+	return merge(older, newer, LastWins)
+}
+
+// DropTombstones answers an immutable copy of the specified range without its tombstones.
+// This is only safe once the range contains the oldest copy of the data it covers, for
+// example once it has been merged with every older range, since after the tombstones are
+// dropped they can no longer suppress older elements.
+func DropTombstones(r SortedRange) SortedRange {
+	return newImmutableRange(AsSlice(Visible(r)))
+}
@@ -0,0 +1,156 @@
+package tsl
+
+import (
+	"reflect"
+	"testing"
+)
+
+// deletableElement is an Element that can represent a Tombstone.
+type deletableElement struct {
+	key     int
+	deleted bool
+}
+
+func (d deletableElement) Less(e Element) bool {
+	return d.key < e.(deletableElement).key
+}
+
+func (d deletableElement) IsTombstone() bool {
+	return d.deleted
+}
+
+func live(keys ...int) Elements {
+	result := Elements{}
+	for _, k := range keys {
+		result = append(result, deletableElement{key: k})
+	}
+	return result
+}
+
+func tombstones(keys ...int) Elements {
+	result := Elements{}
+	for _, k := range keys {
+		result = append(result, deletableElement{key: k, deleted: true})
+	}
+	return result
+}
+
+func Test_Tombstone_Merge(t *testing.T) {
+	a := newImmutableRange(live(1, 2, 3, 4))
+	b := newImmutableRange(tombstones(2, 4))
+	merged := MergeWith(a, b, KeepFirst)
+
+	got := Elements(AsSlice(merged))
+	expected := Elements{live(1)[0], tombstones(2)[0], live(3)[0], tombstones(4)[0]}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("merge should keep tombstones. got: %v, expected: %v", got, expected)
+	}
+
+	visible := Visible(merged)
+	got = Elements(AsSlice(visible))
+	expected = live(1, 3)
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("visible. got: %v, expected: %v", got, expected)
+	}
+	if err := checkSortedRangeInvariants(visible); err != nil {
+		t.Fatalf("got: %v. %v", visible, err)
+	}
+}
+
+func Test_Tombstone_Reinsert(t *testing.T) {
+	r := NewUnsortedRange()
+	r.Add(live(1, 2))
+	r.Add(tombstones(2))
+	r.Add(live(2))
+	got := Elements(AsSlice(Visible(r.Freeze())))
+	expected := live(1, 2)
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("an element added after a tombstone should be visible. got: %v, expected: %v", got, expected)
+	}
+}
+
+func Test_Tombstone_AllDeleted(t *testing.T) {
+	merged := Merge(newImmutableRange(live(1, 2)), newImmutableRange(tombstones(1, 2)))
+	visible := Visible(merged)
+	if err := checkSortedRangeInvariants(visible); err != nil {
+		t.Fatalf("got: %v. %v", visible, err)
+	}
+	if visible.Limit() != 0 {
+		t.Fatalf("a range of tombstones should be empty. got: %v", AsSlice(visible))
+	}
+}
+
+func Test_Tombstone_DropTombstones(t *testing.T) {
+	merged := Merge(newImmutableRange(live(1, 2, 3)), newImmutableRange(tombstones(2)))
+	got := DropTombstones(merged)
+	expected := live(1, 3)
+	if !reflect.DeepEqual(Elements(AsSlice(got)), expected) {
+		t.Fatalf("drop tombstones. got: %v, expected: %v", AsSlice(got), expected)
+	}
+	if _, ok := got.(*immutableRange); !ok {
+		t.Fatalf("compacted range should be immutable. got: %v", got)
+	}
+}
+
+func Test_DeleteRange(t *testing.T) {
+	r := Merge(newImmutableRange(NewElements([]int{0, 2, 4, 6})), newImmutableRange(NewElements([]int{1, 3, 5, 7})))
+	got := DeleteRange(r, intElement{2}, intElement{5})
+	expected := NewElements([]int{0, 1, 5, 6, 7})
+	if !reflect.DeepEqual(Elements(AsSlice(got)), expected) {
+		t.Fatalf("delete range. got: %v, expected: %v", AsSlice(got), expected)
+	}
+	if err := checkSortedRangeInvariants(got); err != nil {
+		t.Fatalf("got: %v. %v", got, err)
+	}
+}
+
+func Test_Log_Deletes(t *testing.T) {
+	l := NewLog()
+	l.Append(live(1, 2, 3, 4, 5, 6))
+	l.Snapshot()
+	l.Append(tombstones(2))
+	l.DeleteRange(deletableElement{key: 4}, deletableElement{key: 6})
+	l.Append(live(5))
+
+	got := Elements(AsSlice(l.Snapshot()))
+	expected := live(1, 3, 5, 6)
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("snapshot. got: %v, expected: %v", got, expected)
+	}
+
+	a := &sliceArchiver{}
+	if err := l.Archive(deletableElement{key: 4}, a); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	archived := []Elements{{live(1)[0], tombstones(2)[0], live(3)[0]}}
+	if !reflect.DeepEqual(a.archived, archived) {
+		t.Fatalf("archived tombstones. got: %v, expected: %v", a.archived, archived)
+	}
+}
+
+func Test_Tombstone_VisibleBoundsAreLazy(t *testing.T) {
+	r := Merge(newImmutableRange(NewElements([]int{1, 2, 4})), newImmutableRange(NewElements([]int{0, 3})))
+	visible := Visible(r)
+	if visible.First() != (intElement{0}) || visible.Last() != (intElement{4}) || visible.Limit() != r.Limit() {
+		t.Fatalf("bounds. got: %v, %v, %d", visible.First(), visible.Last(), visible.Limit())
+	}
+	if m, ok := r.(*mergeableRange); !ok || m.left == nil {
+		t.Fatalf("the bounds of a range without tombstones should not complete its merge. got: %v", r)
+	}
+}
+
+func Test_Tombstone_SnapshotWithoutTombstones(t *testing.T) {
+	l := NewLog()
+	l.Append(live(1, 2))
+	if _, ok := l.Snapshot().(*visibleRange); ok {
+		t.Fatalf("a snapshot of a log without tombstones should not hide tombstones")
+	}
+	l.Append(tombstones(1))
+	snapshot := l.Snapshot()
+	if _, ok := snapshot.(*visibleRange); !ok {
+		t.Fatalf("a snapshot of a log with tombstones should hide them. got: %v", snapshot)
+	}
+	if got := Elements(AsSlice(snapshot)); !reflect.DeepEqual(got, live(2)) {
+		t.Fatalf("snapshot. got: %v, expected: %v", got, live(2))
+	}
+}
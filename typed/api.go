@@ -0,0 +1,125 @@
+// Package typed provides a type-safe variant of the tsl API using type parameters.
+//
+// The ranges in this package store values of the element type directly, rather than
+// boxing them in an Element interface, and order them with a comparison function
+// supplied when the range is created. This means plain structs can be stored in a range
+// without allocating for each element and without the unchecked type assertions that
+// are needed to implement tsl.Element.
+//
+// The semantics of the API mirror the tsl package: an UnsortedRange accumulates values
+// until it is frozen, a frozen range is a SortedRange which can be iterated with a
+// Cursor and partitioned, and two SortedRanges can be merged. Where two values compare
+// equal, the one added later (or, for Merge, the one from the second range) is kept.
+package typed
+
+import (
+	"errors"
+)
+
+var (
+	// ErrAlreadyFrozen is returned by UnsortedRange.Add if the range has been frozen
+	ErrAlreadyFrozen = errors.New("error attempting to add elements to a frozen range.")
+)
+
+// Compare is a function that compares two values, returning a negative number
+// if a sorts before b, a positive number if a sorts after b and zero if a
+// and b are equal. cmp.Compare is a Compare function for ordered types.
+type Compare[T any] func(a, b T) int
+
+// Order determines which partition of a SortedRange receives the elements
+// that are equal to the element at which the range is partitioned.
+type Order int
+
+const (
+	// LessOrder places elements that are equal to the partitioning element in the second partition.
+	LessOrder Order = iota
+	// LessOrEqualOrder places elements that are equal to the partitioning element in the first partition.
+	LessOrEqualOrder
+)
+
+// before answers true if a belongs in the first partition of a range partitioned at e.
+func (o Order) before(c int) bool {
+	if o == LessOrder {
+		return c < 0
+	}
+	return c <= 0
+}
+
+// A Range knows the first and last elements of its range and
+// knows the maximum number of elements that it may contain.
+type Range[T any] interface {
+	// Limit is the maximum number of elements in the Range. The actual number may be less.
+	Limit() int
+	// First answers the first element in the Range and false if the Range is empty.
+	First() (T, bool)
+	// Last answers the last element in the Range and false if the Range is empty.
+	Last() (T, bool)
+}
+
+// A Cursor is used to obtain the next element from
+// a SortedRange. There may be multiple Cursors over a single SortedRange.
+type Cursor[T any] interface {
+	// Next answers the next element and true, or the zero value and false if there are no more elements.
+	Next() (T, bool)
+	// Fill buffer with at most len(buffer) elements, returning the number of elements
+	// actually filled.
+	Fill(buffer []T) int
+}
+
+// A SortedRange is a Range that can provide a Cursor that performs a sorted, deduplicated
+// iteration over its contents and which can be partitioned into a pair of (possibly empty)
+// sub-ranges.
+type SortedRange[T any] interface {
+	Range[T]
+	// Open a cursor that iterates over the deduplicated elements of the receiver in sorted order.
+	Open() Cursor[T]
+	// Partition the receiver into two SortedRanges A and B such that each element of A
+	// sorts before e (or is equal to e, if o is LessOrEqualOrder) and no element of B does.
+	Partition(e T, o Order) (SortedRange[T], SortedRange[T])
+}
+
+// A UnsortedRange can have slices of elements added to it, but it cannot be
+// read directly. To read elements from an UnsortedRange, call Freeze() to obtain
+// a SortedRange.
+type UnsortedRange[T any] interface {
+	Range[T]
+	// Adds the specified elements to the receiver. If the receiver has already been frozen
+	// then ErrAlreadyFrozen is returned.
+	Add(elements []T) error
+	// Freezes the UnsortedRange, returning a SortedRange for the contained elements.
+	// This method is idempotent.
+	Freeze() SortedRange[T]
+}
+
+// NewUnsortedRange returns an UnsortedRange whose elements are ordered by the specified function.
+func NewUnsortedRange[T any](cmp Compare[T]) UnsortedRange[T] {
+	return &mutableRange[T]{
+		cmp: cmp,
+	}
+}
+
+// NewSortedRange returns a SortedRange for a slice that is already sorted and deduplicated
+// according to the specified function. It is the caller's responsibility to ensure that
+// the slice is not subsequently modified.
+func NewSortedRange[T any](cmp Compare[T], sorted []T) SortedRange[T] {
+	return newSliceRange(cmp, sorted)
+}
+
+// Empty returns a SortedRange that has no elements.
+func Empty[T any]() SortedRange[T] {
+	return &sliceRange[T]{}
+}
+
+// AsSlice converts a SortedRange into a slice.
+func AsSlice[T any](r SortedRange[T]) []T {
+	result := make([]T, r.Limit())
+	n := r.Open().Fill(result)
+	return result[0:n]
+}
+
+// Merge merges two SortedRanges to produce a third SortedRange which represents the
+// deduplicated merge of the two original ranges. Where two elements from a and
+// b are equal, the resulting SortedRange contains the element from b.
+func Merge[T any](cmp Compare[T], a SortedRange[T], b SortedRange[T]) SortedRange[T] {
+	return merge(cmp, a, b)
+}
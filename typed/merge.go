@@ -0,0 +1,337 @@
+package typed
+
+import (
+	"sort"
+	"sync"
+)
+
+// mergeRange represents a possibly incomplete merge of two SortedRanges. The
+// merge is advanced by cursors as they reach the end of the merged part of
+// the range. Once the merge is complete, the arms of the merge are released.
+type mergeRange[T any] struct {
+	mu       sync.RWMutex
+	cmp      Compare[T]
+	first    T
+	last     T
+	left     SortedRange[T]
+	right    SortedRange[T]
+	lx       *peekCursor[T] // a peekable cursor into the left arm of the merge
+	rx       *peekCursor[T] // a peekable cursor into the right arm of the merge
+	elements []T
+	mx       int // number of copied elements
+	nx       int // number of deduplicated elements
+}
+
+func newMergeRange[T any](cmp Compare[T], left SortedRange[T], right SortedRange[T]) *mergeRange[T] {
+	r := &mergeRange[T]{
+		cmp:      cmp,
+		left:     left,
+		right:    right,
+		lx:       &peekCursor[T]{underlying: left.Open()},
+		rx:       &peekCursor[T]{underlying: right.Open()},
+		elements: make([]T, left.Limit()+right.Limit()),
+	}
+	r.first, r.last = selectFirst(cmp, left, right), selectLast(cmp, left, right)
+	return r
+}
+
+func (r *mergeRange[T]) Limit() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.elements)
+}
+
+func (r *mergeRange[T]) First() (T, bool) {
+	return r.first, true
+}
+
+func (r *mergeRange[T]) Last() (T, bool) {
+	return r.last, true
+}
+
+// mergeOne advances nx so that it represents the length of the merged, deduplicated slice and advances
+// mx to point to the location of the next element to be written into the merged slice.
+// Must be called while holding the write lock.
+func (r *mergeRange[T]) mergeOne() {
+	if r.left == nil {
+		return
+	}
+	r.nx = r.mx
+	for r.nx == r.mx {
+		l, lok := r.lx.peek()
+		rr, rok := r.rx.peek()
+		if !lok && !rok {
+			break
+		}
+		var e T
+		if !rok || (lok && r.cmp(l, rr) < 0) {
+			e = r.lx.next()
+		} else if !lok || r.cmp(rr, l) < 0 {
+			e = r.rx.next()
+		} else {
+			r.lx.next()
+			e = r.rx.next()
+		}
+		if r.mx > 0 && r.cmp(r.elements[r.mx-1], e) == 0 {
+			r.elements[r.mx-1] = e
+			continue
+		}
+		r.elements[r.mx] = e
+		r.mx++
+		if r.mx == 1 {
+			r.nx = r.mx
+		}
+	}
+	_, lok := r.lx.peek()
+	_, rok := r.rx.peek()
+	if !lok && !rok {
+		r.left, r.right, r.lx, r.rx = nil, nil, nil, nil
+		r.nx = r.mx
+		r.elements = r.elements[0:r.mx]
+	}
+}
+
+func (r *mergeRange[T]) Open() Cursor[T] {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.left == nil {
+		return &sliceCursor[T]{elements: r.elements}
+	}
+	return &mergeCursor[T]{
+		r:        r,
+		elements: r.elements,
+	}
+}
+
+// Partition partitions the merged slice if the merge is complete or the arms
+// of the merge otherwise.
+func (r *mergeRange[T]) Partition(e T, o Order) (SortedRange[T], SortedRange[T]) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.left == nil {
+		return newSliceRange(r.cmp, r.elements).Partition(e, o)
+	}
+	p1, p2 := r.left.Partition(e, o)
+	p3, p4 := r.right.Partition(e, o)
+	return merge(r.cmp, p1, p3), merge(r.cmp, p2, p4)
+}
+
+// mergeCursor iterates across the merged part of a mergeRange, advancing
+// the merge when it reaches the end of the merged part.
+type mergeCursor[T any] struct {
+	r        *mergeRange[T]
+	next     int
+	elements []T
+}
+
+func (c *mergeCursor[T]) Next() (T, bool) {
+	c.r.mu.RLock()
+	if c.next == c.r.nx {
+		c.r.mu.RUnlock()
+		c.r.mu.Lock()
+		c.r.mergeOne()
+		c.r.mu.Unlock()
+		c.r.mu.RLock()
+	}
+	defer c.r.mu.RUnlock()
+	if c.next == c.r.nx {
+		var zero T
+		return zero, false
+	}
+	c.next++
+	return c.elements[c.next-1], true
+}
+
+func (c *mergeCursor[T]) Fill(buffer []T) int {
+	c.r.mu.RLock()
+	n := copy(buffer, c.elements[c.next:c.r.nx])
+	c.r.mu.RUnlock()
+	c.next += n
+	for n < len(buffer) {
+		e, ok := c.Next()
+		if !ok {
+			break
+		}
+		buffer[n] = e
+		n++
+	}
+	return n
+}
+
+// peekCursor is a cursor with a lookahead of 1. It is used to iterate
+// over one of the arms of a merge.
+type peekCursor[T any] struct {
+	underlying Cursor[T]
+	peeked     T
+	ok         bool
+	done       bool
+}
+
+func (pc *peekCursor[T]) peek() (T, bool) {
+	if !pc.ok && !pc.done {
+		pc.peeked, pc.ok = pc.underlying.Next()
+		pc.done = !pc.ok
+	}
+	return pc.peeked, pc.ok
+}
+
+func (pc *peekCursor[T]) next() T {
+	e, _ := pc.peek()
+	pc.ok = false
+	return e
+}
+
+// disjointRange represents a slice of SortedRanges which do not
+// overlap and which are ordered from first to last.
+type disjointRange[T any] struct {
+	cmp      Compare[T]
+	segments []SortedRange[T]
+}
+
+func (d *disjointRange[T]) Limit() int {
+	limit := 0
+	for _, s := range d.segments {
+		limit += s.Limit()
+	}
+	return limit
+}
+
+func (d *disjointRange[T]) First() (T, bool) {
+	return d.segments[0].First()
+}
+
+func (d *disjointRange[T]) Last() (T, bool) {
+	return d.segments[len(d.segments)-1].Last()
+}
+
+func (d *disjointRange[T]) Open() Cursor[T] {
+	return &disjointCursor[T]{
+		cursor:   d.segments[0].Open(),
+		segments: d.segments,
+	}
+}
+
+// Partition uses a binary search to find the segment that contains the partition boundary
+// and partitions only that segment.
+func (d *disjointRange[T]) Partition(e T, o Order) (SortedRange[T], SortedRange[T]) {
+	i := sort.Search(len(d.segments), func(i int) bool {
+		last, _ := d.segments[i].Last()
+		return !o.before(d.cmp(last, e))
+	})
+	if i == len(d.segments) {
+		return d, Empty[T]()
+	}
+	p1, p2 := d.segments[i].Partition(e, o)
+	left := append(append([]SortedRange[T]{}, d.segments[0:i]...), p1)
+	right := append([]SortedRange[T]{p2}, d.segments[i+1:]...)
+	return newDisjointRange(d.cmp, left), newDisjointRange(d.cmp, right)
+}
+
+// newDisjointRange answers a SortedRange for the non-empty members of segments.
+func newDisjointRange[T any](cmp Compare[T], segments []SortedRange[T]) SortedRange[T] {
+	nonEmpty := make([]SortedRange[T], 0, len(segments))
+	for _, s := range segments {
+		if d, ok := s.(*disjointRange[T]); ok {
+			nonEmpty = append(nonEmpty, d.segments...)
+		} else if s.Limit() > 0 {
+			nonEmpty = append(nonEmpty, s)
+		}
+	}
+	switch len(nonEmpty) {
+	case 0:
+		return Empty[T]()
+	case 1:
+		return nonEmpty[0]
+	default:
+		return &disjointRange[T]{
+			cmp:      cmp,
+			segments: nonEmpty,
+		}
+	}
+}
+
+type disjointCursor[T any] struct {
+	next     int
+	cursor   Cursor[T]
+	segments []SortedRange[T]
+}
+
+func (c *disjointCursor[T]) advance() bool {
+	c.next++
+	if c.next < len(c.segments) {
+		c.cursor = c.segments[c.next].Open()
+		return true
+	}
+	c.cursor = nil
+	return false
+}
+
+func (c *disjointCursor[T]) Next() (T, bool) {
+	for c.cursor != nil {
+		if e, ok := c.cursor.Next(); ok {
+			return e, true
+		}
+		c.advance()
+	}
+	var zero T
+	return zero, false
+}
+
+func (c *disjointCursor[T]) Fill(buffer []T) int {
+	n := 0
+	for n < len(buffer) && c.cursor != nil {
+		n += c.cursor.Fill(buffer[n:])
+		if n < len(buffer) {
+			c.advance()
+		}
+	}
+	return n
+}
+
+// selectFirst answers the lesser of the first elements of a and b, preferring b if they are equal.
+func selectFirst[T any](cmp Compare[T], a, b Range[T]) T {
+	af, aok := a.First()
+	bf, bok := b.First()
+	if !bok || (aok && cmp(af, bf) < 0) {
+		return af
+	}
+	return bf
+}
+
+// selectLast answers the greater of the last elements of a and b, preferring b if they are equal.
+func selectLast[T any](cmp Compare[T], a, b Range[T]) T {
+	al, aok := a.Last()
+	bl, bok := b.Last()
+	if !bok || (aok && cmp(bl, al) < 0) {
+		return al
+	}
+	return bl
+}
+
+// merge answers the disjoint concatenation of a and b if they do not overlap and
+// otherwise only merges the overlapping parts of a and b.
+func merge[T any](cmp Compare[T], a SortedRange[T], b SortedRange[T]) SortedRange[T] {
+	if a.Limit() == 0 {
+		return b
+	} else if b.Limit() == 0 {
+		return a
+	}
+	alast, _ := a.Last()
+	bfirst, _ := b.First()
+	if cmp(alast, bfirst) < 0 {
+		return newDisjointRange(cmp, []SortedRange[T]{a, b})
+	}
+
+	p1, p2 := a.Partition(bfirst, LessOrder)
+	p3, p4 := b.Partition(alast, LessOrEqualOrder)
+
+	var m23 SortedRange[T]
+	if p2.Limit() == 0 {
+		m23 = p3
+	} else if p3.Limit() == 0 {
+		m23 = p2
+	} else {
+		m23 = newMergeRange(cmp, p2, p3)
+	}
+	return newDisjointRange(cmp, []SortedRange[T]{p1, m23, p4})
+}
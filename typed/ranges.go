@@ -0,0 +1,169 @@
+package typed
+
+import (
+	"slices"
+	"sort"
+	"sync"
+)
+
+// sliceRange is a SortedRange over an immutable, sorted, deduplicated slice.
+type sliceRange[T any] struct {
+	cmp      Compare[T]
+	elements []T
+}
+
+func newSliceRange[T any](cmp Compare[T], sorted []T) *sliceRange[T] {
+	return &sliceRange[T]{
+		cmp:      cmp,
+		elements: sorted,
+	}
+}
+
+func (r *sliceRange[T]) Limit() int {
+	return len(r.elements)
+}
+
+func (r *sliceRange[T]) First() (T, bool) {
+	if len(r.elements) == 0 {
+		var zero T
+		return zero, false
+	}
+	return r.elements[0], true
+}
+
+func (r *sliceRange[T]) Last() (T, bool) {
+	if len(r.elements) == 0 {
+		var zero T
+		return zero, false
+	}
+	return r.elements[len(r.elements)-1], true
+}
+
+func (r *sliceRange[T]) Open() Cursor[T] {
+	return &sliceCursor[T]{
+		elements: r.elements,
+	}
+}
+
+// Partition uses a binary search to split the slice into two sub-slices that share
+// storage with the receiver.
+func (r *sliceRange[T]) Partition(e T, o Order) (SortedRange[T], SortedRange[T]) {
+	found := sort.Search(len(r.elements), func(i int) bool {
+		return !o.before(r.cmp(r.elements[i], e))
+	})
+	return newSliceRange(r.cmp, r.elements[0:found]), newSliceRange(r.cmp, r.elements[found:])
+}
+
+// sliceCursor is a cursor over an immutable, sorted, deduplicated slice.
+type sliceCursor[T any] struct {
+	next     int
+	elements []T
+}
+
+func (c *sliceCursor[T]) Next() (T, bool) {
+	if c.next < len(c.elements) {
+		c.next++
+		return c.elements[c.next-1], true
+	}
+	var zero T
+	return zero, false
+}
+
+func (c *sliceCursor[T]) Fill(buffer []T) int {
+	n := copy(buffer, c.elements[c.next:])
+	c.next += n
+	return n
+}
+
+// mutableRange is an UnsortedRange that keeps the elements that arrive in order
+// separately from those that arrive out of order so that only the latter need to
+// be sorted when the range is frozen.
+type mutableRange[T any] struct {
+	mu       sync.RWMutex
+	cmp      Compare[T]
+	first    T
+	last     T
+	elements []T // the elements that arrived in order
+	unsorted []T // the elements that arrived out of order
+	frozen   SortedRange[T]
+}
+
+func (r *mutableRange[T]) Limit() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.elements) + len(r.unsorted)
+}
+
+func (r *mutableRange[T]) First() (T, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.first, len(r.elements) > 0
+}
+
+func (r *mutableRange[T]) Last() (T, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.last, len(r.elements) > 0
+}
+
+func (r *mutableRange[T]) addOne(e T) {
+	if len(r.elements) == 0 {
+		r.elements = append(r.elements, e)
+		r.first = e
+		r.last = e
+		return
+	}
+	if r.cmp(r.last, e) < 0 {
+		r.elements = append(r.elements, e)
+		r.last = e
+	} else {
+		r.unsorted = append(r.unsorted, e)
+		if r.cmp(e, r.first) < 0 {
+			r.first = e
+		}
+	}
+}
+
+func (r *mutableRange[T]) Add(elements []T) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.frozen != nil {
+		return ErrAlreadyFrozen
+	}
+	for _, e := range elements {
+		r.addOne(e)
+	}
+	return nil
+}
+
+// Freeze sorts and deduplicates the elements that arrived out of order and answers
+// a lazy merge of them with the elements that arrived in order.
+func (r *mutableRange[T]) Freeze() SortedRange[T] {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.frozen == nil {
+		sorted := newSliceRange(r.cmp, r.elements)
+		if len(r.unsorted) == 0 {
+			r.frozen = sorted
+		} else {
+			slices.SortStableFunc(r.unsorted, r.cmp)
+			r.frozen = newMergeRange(r.cmp, sorted, newSliceRange(r.cmp, deduplicate(r.cmp, r.unsorted)))
+		}
+	}
+	return r.frozen
+}
+
+// deduplicate removes all but the last of each run of equal elements from a sorted slice.
+func deduplicate[T any](cmp Compare[T], sorted []T) []T {
+	j := 0
+	for _, e := range sorted {
+		if j > 0 && cmp(sorted[j-1], e) == 0 {
+			j--
+		}
+		sorted[j] = e
+		j++
+	}
+	return sorted[0:j]
+}
@@ -0,0 +1,116 @@
+package typed
+
+import (
+	"cmp"
+	"math/rand"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// point is a plain struct ordered by its timestamp.
+type point struct {
+	ts    int
+	value string
+}
+
+func comparePoints(a, b point) int {
+	return cmp.Compare(a.ts, b.ts)
+}
+
+func points(values ...int) []point {
+	result := make([]point, len(values))
+	for i, v := range values {
+		result[i] = point{ts: v}
+	}
+	return result
+}
+
+func Test_UnsortedRange_Freeze(t *testing.T) {
+	r := NewUnsortedRange(comparePoints)
+	r.Add(points(0, 2, 3, 4, 6, 6, 3, 2, 1, 5, 7))
+	got := AsSlice(r.Freeze())
+	expected := points(0, 1, 2, 3, 4, 5, 6, 7)
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("sort failed. got: %v, expected: %v", got, expected)
+	}
+	if err := r.Add(points(8)); err != ErrAlreadyFrozen {
+		t.Fatalf("add after freeze. got: %v, expected: %v", err, ErrAlreadyFrozen)
+	}
+}
+
+func Test_UnsortedRange_LastWins(t *testing.T) {
+	r := NewUnsortedRange(comparePoints)
+	r.Add([]point{{1, "a"}, {2, "a"}, {1, "b"}, {2, "b"}, {1, "c"}})
+	got := AsSlice(r.Freeze())
+	expected := []point{{1, "c"}, {2, "b"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("deduplication failed. got: %v, expected: %v", got, expected)
+	}
+}
+
+func Test_SortedRange_Partition(t *testing.T) {
+	r := NewUnsortedRange(comparePoints)
+	r.Add(points(1, 0, 3, 2, 5, 4))
+	frozen := r.Freeze()
+
+	p1, p2 := frozen.Partition(point{ts: 2}, LessOrder)
+	if got, expected := AsSlice(p1), points(0, 1); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("partition p1. got: %v, expected: %v", got, expected)
+	}
+	if got, expected := AsSlice(p2), points(2, 3, 4, 5); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("partition p2. got: %v, expected: %v", got, expected)
+	}
+
+	p1, p2 = frozen.Partition(point{ts: 2}, LessOrEqualOrder)
+	if got, expected := AsSlice(p1), points(0, 1, 2); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("partition p1. got: %v, expected: %v", got, expected)
+	}
+	if got, expected := AsSlice(p2), points(3, 4, 5); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("partition p2. got: %v, expected: %v", got, expected)
+	}
+}
+
+func Test_Merge(t *testing.T) {
+	a := NewSortedRange(comparePoints, []point{{0, "a"}, {2, "a"}, {4, "a"}})
+	b := NewSortedRange(comparePoints, []point{{2, "b"}, {3, "b"}, {6, "b"}})
+	got := AsSlice(Merge(comparePoints, a, b))
+	expected := []point{{0, "a"}, {2, "b"}, {3, "b"}, {4, "a"}, {6, "b"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("merge failed. got: %v, expected: %v", got, expected)
+	}
+
+	disjoint := Merge(comparePoints, b, NewSortedRange(comparePoints, points(7, 8)))
+	if first, _ := disjoint.First(); first.ts != 2 {
+		t.Fatalf("first of disjoint merge. got: %v, expected: 2", first)
+	}
+	if last, _ := disjoint.Last(); last.ts != 8 {
+		t.Fatalf("last of disjoint merge. got: %v, expected: 8", last)
+	}
+}
+
+func Test_Merge_Random(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	acc := Empty[int]()
+	expected := []int{}
+	for i := 0; i < 20; i++ {
+		r := NewUnsortedRange(cmp.Compare[int])
+		for j := 0; j < 100; j++ {
+			v := rng.Intn(1000)
+			r.Add([]int{v})
+			expected = append(expected, v)
+		}
+		acc = Merge(cmp.Compare[int], acc, r.Freeze())
+	}
+	slices.Sort(expected)
+	expected = slices.Compact(expected)
+
+	lo, hi := acc.Partition(500, LessOrder)
+	got := append(AsSlice(lo), AsSlice(hi)...)
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("merge failed. got: %v, expected: %v", got, expected)
+	}
+	if got := AsSlice(acc); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("merge failed. got: %v, expected: %v", got, expected)
+	}
+}
@@ -1,17 +1,18 @@
 package tsl
 
 import (
-	"sort"
 	"sync"
 )
 
 // unsortedRange is a basicRange that can be extended with
 // additional unsorted elements. Freezing an unsorted range
-// incurs an O(n.log(n)) sort.
+// incurs a run-aware sort which is O(n.log(n)) in the worst case
+// but close to O(n) if the elements are nearly sorted.
 type unsortedRange struct {
 	basicRange
-	mu     sync.RWMutex
-	frozen *immutableRange
+	mu      sync.RWMutex
+	frozen  *immutableRange
+	resolve Resolver
 }
 
 // add adds a single element to the unsorted range, updating
@@ -19,7 +20,11 @@ type unsortedRange struct {
 func (r *unsortedRange) add(e Element) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	r.addLocked(e)
+}
 
+// addLocked is add for callers that already hold the write lock.
+func (r *unsortedRange) addLocked(e Element) {
 	if r.frozen != nil {
 		panic("attempt to add to frozen range")
 	}
@@ -46,7 +51,7 @@ func (r *unsortedRange) freeze() SortedRange {
 	defer r.mu.Unlock()
 
 	if r.frozen == nil {
-		sort.Stable(Elements(r.elements))
+		runSort(r.elements)
 		r.deduplicate()
 		r.frozen = &immutableRange{
 			basicRange: basicRange{
@@ -60,23 +65,25 @@ func (r *unsortedRange) freeze() SortedRange {
 	return r.frozen
 }
 
-// deduplicate ensures on the the last of equal Elements
-// is kept.
+// deduplicate combines each run of equal Elements into a single
+// Element with the range's resolver. Since the sort is stable, the
+// elements of each run are resolved in the order they were added.
 func (r *unsortedRange) deduplicate() {
 	if len(r.elements) < 2 {
 		return
 	}
 	j := 0
 	for _, e := range r.elements {
-		if j > 0 {
-			if !r.elements[j-1].Less(e) {
-				j--
-			}
+		if j > 0 && !r.elements[j-1].Less(e) {
+			r.elements[j-1] = r.resolve.apply(r.elements[j-1], e)
+			continue
 		}
 		r.elements[j] = e
 		j++
 	}
 	r.elements = r.elements[0:j]
+	r.first = r.elements[0]
+	r.last = r.elements[j-1]
 }
 
 func (r *unsortedRange) String() string {
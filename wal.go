@@ -0,0 +1,321 @@
+package tsl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// A write-ahead log (WAL) is a file that records each batch of elements
+// passed to UnsortedRange.Add before the batch is acknowledged. A WAL has the
+// following layout:
+//
+//	header: magic "TSLWAL" | version (1 byte) | reserved (1 byte)
+//	record: payload length (4 bytes) | payload crc32 (4 bytes) |
+//	        uvarint(count) | (uvarint(len) | encoded element)*
+//
+// All integers in the record header are little endian. Checksums are IEEE CRC-32.
+//
+// A record that is truncated or fails its checksum marks the end of the log. Such a
+// record can only be the result of a write that was interrupted by a crash and so was
+// never acknowledged.
+
+var (
+	// ErrBadWAL is returned by RecoverWAL and OpenWAL if the file is not a write-ahead log.
+	ErrBadWAL = errors.New("malformed write-ahead log.")
+)
+
+const (
+	walVersion          = 1
+	walHeaderSize       = 8
+	walRecordHeaderSize = 8
+)
+
+var walMagic = []byte("TSLWAL")
+
+// SyncPolicy determines when a write-ahead log is synced to stable storage.
+type SyncPolicy int
+
+const (
+	// SyncAlways syncs the log before each call to Add returns.
+	SyncAlways SyncPolicy = iota
+	// SyncInterval syncs the log during a call to Add if the log has not
+	// been synced for at least WALOptions.Interval.
+	SyncInterval
+	// SyncNever leaves syncing to the operating system. Acknowledged writes
+	// survive a crash of the process, but not of the host.
+	SyncNever
+)
+
+// WALOptions configure the behaviour of a write-ahead log.
+type WALOptions struct {
+	// Policy determines when the log is synced to stable storage.
+	Policy SyncPolicy
+	// Interval is the maximum time between syncs when Policy is SyncInterval.
+	Interval time.Duration
+}
+
+// A WALRange is an UnsortedRange that records each batch of added elements in
+// a write-ahead log before acknowledging the batch.
+type WALRange interface {
+	UnsortedRange
+	// Sync syncs the write-ahead log to stable storage.
+	Sync() error
+	// Close syncs and closes the write-ahead log. The range may still be frozen and
+	// read after it is closed, but further calls to Add will fail.
+	Close() error
+}
+
+// walRange is an UnsortedRange whose additions are recorded in a write-ahead log.
+// The mutex serializes writes to the log so that the order of records in the log
+// matches the order in which batches are added to the underlying range.
+type walRange struct {
+	mu       sync.Mutex
+	file     *os.File
+	codec    Codec
+	options  WALOptions
+	lastSync time.Time
+	end      int64 // the offset of the end of the last complete record
+	err      error // the error that left the log in an unknown state, if any
+	frozen   bool
+	UnsortedRange
+}
+
+// OpenWAL opens the write-ahead log with the specified name, creating it if it does not exist,
+// and answers a WALRange that contains the elements recovered from the log and which appends
+// further additions to the log. Any partially written record at the end of the log is discarded.
+func OpenWAL(name string, codec Codec, options WALOptions) (WALRange, error) {
+	file, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+
+	recovered, end, err := func() (UnsortedRange, int64, error) {
+		fi, err := file.Stat()
+		if err != nil {
+			return nil, 0, err
+		}
+		if fi.Size() == 0 {
+			header := append(append([]byte{}, walMagic...), walVersion, 0)
+			if _, err := file.Write(header); err != nil {
+				return nil, 0, err
+			}
+			return NewUnsortedRange(), walHeaderSize, file.Sync()
+		}
+		return recoverWAL(bufio.NewReader(file), fi.Size(), codec)
+	}()
+	if err == nil {
+		if err = file.Truncate(end); err == nil {
+			_, err = file.Seek(end, io.SeekStart)
+		}
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &walRange{
+		file:          file,
+		codec:         codec,
+		options:       options,
+		lastSync:      time.Now(),
+		end:           end,
+		UnsortedRange: recovered,
+	}, nil
+}
+
+// RecoverWAL replays the write-ahead log read from r into a new UnsortedRange. Because
+// batches are replayed in the order they were originally added, the in-order part of
+// the range and the part that arrived out of order are rebuilt exactly as they were
+// before the restart, so only the out of order part needs to be sorted when the range
+// is frozen.
+func RecoverWAL(r io.Reader, codec Codec) (UnsortedRange, error) {
+	recovered, _, err := recoverWAL(bufio.NewReader(r), -1, codec)
+	return recovered, err
+}
+
+// recoverWAL answers the UnsortedRange recovered from r, which holds size bytes or an
+// unknown number of bytes if size is negative, and the offset of the end of the last
+// complete record.
+func recoverWAL(r io.Reader, size int64, codec Codec) (UnsortedRange, int64, error) {
+	header := make([]byte, walHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, 0, ErrBadWAL
+	}
+	if !bytes.Equal(header[0:len(walMagic)], walMagic) || header[len(walMagic)] != walVersion {
+		return nil, 0, ErrBadWAL
+	}
+
+	recovered := NewUnsortedRange()
+	end := int64(walHeaderSize)
+	recordHeader := make([]byte, walRecordHeaderSize)
+	for {
+		if _, err := io.ReadFull(r, recordHeader); err != nil {
+			return recovered, end, nil
+		}
+		payload, ok := readPayload(r, int64(binary.LittleEndian.Uint32(recordHeader[0:4])), size-end-walRecordHeaderSize, size >= 0)
+		if !ok {
+			return recovered, end, nil
+		}
+		if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(recordHeader[4:8]) {
+			return recovered, end, nil
+		}
+		batch, err := decodeBatch(payload, codec)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := recovered.Add(batch); err != nil {
+			return nil, 0, err
+		}
+		end += int64(walRecordHeaderSize + len(payload))
+	}
+}
+
+// readPayload reads a record payload of the specified length from r, answering false if
+// the record is truncated. The length is read from the log and so cannot be trusted: if
+// the number of bytes remaining in the log is known, a length that exceeds it is rejected
+// before the payload is allocated, otherwise the payload grows only as it is read.
+func readPayload(r io.Reader, length int64, remaining int64, known bool) ([]byte, bool) {
+	if known {
+		if length > remaining {
+			return nil, false
+		}
+		payload := make([]byte, length)
+		_, err := io.ReadFull(r, payload)
+		return payload, err == nil
+	}
+	payload, err := io.ReadAll(io.LimitReader(r, length))
+	return payload, err == nil && int64(len(payload)) == length
+}
+
+// encodeBatch answers a WAL record for the specified batch of elements.
+func encodeBatch(elements []Element, codec Codec) ([]byte, error) {
+	record := make([]byte, walRecordHeaderSize)
+	record = binary.AppendUvarint(record, uint64(len(elements)))
+	for _, e := range elements {
+		var err error
+		if record, err = appendElement(record, e, codec); err != nil {
+			return nil, err
+		}
+	}
+	payload := record[walRecordHeaderSize:]
+	binary.LittleEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(payload))
+	return record, nil
+}
+
+// decodeBatch answers the batch of elements encoded in a WAL record payload.
+func decodeBatch(payload []byte, codec Codec) ([]Element, error) {
+	count, payload, err := decodeUvarint(payload)
+	if err != nil {
+		return nil, ErrBadWAL
+	}
+	batch := make([]Element, count)
+	for i := range batch {
+		if batch[i], payload, err = decodeElement(payload, codec); err != nil {
+			return nil, err
+		}
+	}
+	return batch, nil
+}
+
+// Add writes the elements to the write-ahead log, syncing it according to the
+// configured policy, before adding them to the underlying range.
+func (r *walRange) Add(elements []Element) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.frozen {
+		return ErrAlreadyFrozen
+	}
+	if r.file == nil {
+		return os.ErrClosed
+	}
+	if r.err != nil {
+		return r.err
+	}
+
+	record, err := encodeBatch(elements, r.codec)
+	if err != nil {
+		return err
+	}
+	if _, err = r.file.Write(record); err == nil {
+		switch r.options.Policy {
+		case SyncAlways:
+			err = r.sync()
+		case SyncInterval:
+			if time.Since(r.lastSync) >= r.options.Interval {
+				err = r.sync()
+			}
+		}
+	}
+	if err != nil {
+		r.rollback()
+		return err
+	}
+	r.end += int64(len(record))
+
+	return r.UnsortedRange.Add(elements)
+}
+
+// rollback removes a record that was not acknowledged, which may have been partially
+// written, by truncating the log to the end of the last complete record. If the log
+// cannot be truncated, the range fails and further calls to Add answer the error, since
+// a torn record would hide the records written after it from recovery. Must be called
+// while holding the mutex.
+func (r *walRange) rollback() {
+	err := r.file.Truncate(r.end)
+	if err == nil {
+		_, err = r.file.Seek(r.end, io.SeekStart)
+	}
+	if err != nil {
+		r.err = err
+	}
+}
+
+// Freeze prevents further additions to the log and answers the SortedRange
+// of the underlying range.
+func (r *walRange) Freeze() SortedRange {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.frozen = true
+	return r.UnsortedRange.Freeze()
+}
+
+// sync syncs the log. Must be called while holding the mutex.
+func (r *walRange) sync() error {
+	if err := r.file.Sync(); err != nil {
+		return err
+	}
+	r.lastSync = time.Now()
+	return nil
+}
+
+func (r *walRange) Sync() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return os.ErrClosed
+	}
+	return r.sync()
+}
+
+func (r *walRange) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return os.ErrClosed
+	}
+	err := r.sync()
+	if cerr := r.file.Close(); err == nil {
+		err = cerr
+	}
+	r.file = nil
+	return err
+}
@@ -0,0 +1,206 @@
+package tsl
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_WAL_Recover(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "wal")
+	w, err := OpenWAL(name, intCodec{}, WALOptions{Policy: SyncAlways})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	original := &mutableRange{}
+	for _, batch := range [][]int{{0, 2, 3}, {1, 4, 6}, {5, 5, 7}} {
+		if err := w.Add(NewElements(batch)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		original.Add(NewElements(batch))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+	recovered, err := RecoverWAL(f, intCodec{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	m := recovered.(*mutableRange)
+	if !reflect.DeepEqual(m.elements, original.elements) {
+		t.Fatalf("in order elements. got: %v, expected: %v", m.elements, original.elements)
+	}
+	if !reflect.DeepEqual(m.unsorted.elements, original.unsorted.elements) {
+		t.Fatalf("out of order elements. got: %v, expected: %v", m.unsorted.elements, original.unsorted.elements)
+	}
+
+	got := Elements(AsSlice(recovered.Freeze()))
+	expected := NewElements([]int{0, 1, 2, 3, 4, 5, 6, 7})
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("recovered. got: %v, expected: %v", got, expected)
+	}
+}
+
+func Test_WAL_TornWrite(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "wal")
+	w, err := OpenWAL(name, intCodec{}, WALOptions{Policy: SyncNever})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w.Add(NewElements([]int{0, 1}))
+	w.Add(NewElements([]int{2, 3}))
+	w.Close()
+
+	fi, err := os.Stat(name)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.Truncate(name, fi.Size()-1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	w, err = OpenWAL(name, intCodec{}, WALOptions{Policy: SyncInterval})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.Add(NewElements([]int{4})); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w.Close()
+
+	w, err = OpenWAL(name, intCodec{}, WALOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer w.Close()
+
+	got := Elements(AsSlice(w.Freeze()))
+	expected := NewElements([]int{0, 1, 4})
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("recovered. got: %v, expected: %v", got, expected)
+	}
+	if err := w.Add(NewElements([]int{5})); err != ErrAlreadyFrozen {
+		t.Fatalf("add after freeze. got: %v, expected: %v", err, ErrAlreadyFrozen)
+	}
+}
+
+func Test_WAL_NotAWAL(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "wal")
+	if err := os.WriteFile(name, []byte("not a write-ahead log"), 0666); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := OpenWAL(name, intCodec{}, WALOptions{}); err != ErrBadWAL {
+		t.Fatalf("open. got: %v, expected: %v", err, ErrBadWAL)
+	}
+}
+
+func Test_WAL_Rollback(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "wal")
+	w, err := OpenWAL(name, intCodec{}, WALOptions{Policy: SyncNever})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.Add(NewElements([]int{0, 1})); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// a partially written record followed by a failed write is rolled back
+	r := w.(*walRange)
+	record, _ := encodeBatch(NewElements([]int{9}), intCodec{})
+	if _, err := r.file.Write(record[0 : len(record)-1]); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r.rollback()
+	if r.err != nil {
+		t.Fatalf("unexpected error: %v", r.err)
+	}
+
+	if err := w.Add(NewElements([]int{2, 3})); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w.Close()
+
+	w, err = OpenWAL(name, intCodec{}, WALOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer w.Close()
+	got := Elements(AsSlice(w.Freeze()))
+	expected := NewElements([]int{0, 1, 2, 3})
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("recovered. got: %v, expected: %v", got, expected)
+	}
+}
+
+func Test_WAL_FailedWrite(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "wal")
+	w, err := OpenWAL(name, intCodec{}, WALOptions{Policy: SyncNever})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r := w.(*walRange)
+	r.file.Close()
+	first := w.Add(NewElements([]int{0}))
+	if first == nil {
+		t.Fatalf("add to a closed file should fail")
+	}
+	if err := w.Add(NewElements([]int{1})); err == nil {
+		t.Fatalf("add after a failed rollback should fail")
+	}
+	if r.UnsortedRange.Freeze().Limit() != 0 {
+		t.Fatalf("failed additions should not be added to the range")
+	}
+}
+
+func Test_WAL_BadLength(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "wal")
+	w, err := OpenWAL(name, intCodec{}, WALOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w.Add(NewElements([]int{0, 1}))
+	w.Close()
+
+	// a record header whose length exceeds the rest of the log marks the end of the log
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f.Write([]byte{0xf0, 0xff, 0xff, 0xff, 0, 0, 0, 0, 1, 2, 3})
+	f.Close()
+
+	for _, open := range []func() (UnsortedRange, error){
+		func() (UnsortedRange, error) {
+			return OpenWAL(name, intCodec{}, WALOptions{})
+		},
+		func() (UnsortedRange, error) {
+			f, err := os.Open(name)
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			return RecoverWAL(f, intCodec{})
+		},
+	} {
+		recovered, err := open()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got := Elements(AsSlice(recovered.Freeze()))
+		expected := NewElements([]int{0, 1})
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("recovered. got: %v, expected: %v", got, expected)
+		}
+		if w, ok := recovered.(WALRange); ok {
+			w.Close()
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package tsl
+
+import (
+	"time"
+)
+
+// A LatePolicy determines what a Watermark does with late elements, which are
+// elements whose time is before the watermark when they are added.
+type LatePolicy int
+
+const (
+	// DropLate discards late elements.
+	DropLate LatePolicy = iota
+	// SideOutputLate passes late elements to the Late function of the WatermarkOptions
+	// instead of emitting them.
+	SideOutputLate
+	// MergeLate emits late elements with the elements emitted by the same call to Add,
+	// even though they sort before elements that have already been emitted, and warns
+	// of them by passing them to the Late function of the WatermarkOptions.
+	MergeLate
+)
+
+// WatermarkOptions configure a Watermark.
+type WatermarkOptions struct {
+	// Lateness is the allowed lateness: the maximum time by which an element may
+	// be older than the latest element added before it.
+	Lateness time.Duration
+	// Time answers the time of an element. It is required.
+	Time func(e Element) time.Time
+	// Probe answers an element that sorts after every element whose time is before t
+	// and before every other element. It is used to partition the held elements at the
+	// watermark. It is required.
+	Probe func(t time.Time) Element
+	// Policy determines what happens to late elements.
+	Policy LatePolicy
+	// Late, if not nil, is called with the late elements of each call to Add, in sorted
+	// order, by the SideOutputLate and MergeLate policies.
+	Late func(late SortedRange)
+	// Resolve combines equal elements. If nil, LastWins is used.
+	Resolve Resolver
+}
+
+// A Watermark sorts a stream of elements whose lateness is bounded in time rather than
+// in a number of elements. The watermark is the latest time added, less the allowed
+// lateness. Elements are held until the watermark passes them, at which point they are
+// emitted in sorted order. Since elements are emitted as soon as the watermark passes
+// them, a burst of elements does not overflow the watermark, nor does a lull hold
+// elements for longer than the allowed lateness.
+//
+// The ranges answered by successive calls to Add and Flush are in order: every element of
+// one range sorts before every element of the next, except for late elements that are
+// emitted by the MergeLate policy.
+type Watermark interface {
+	// Add adds the specified elements to the receiver and answers the elements,
+	// possibly none, that the watermark has passed.
+	Add(elements []Element) SortedRange
+	// Watermark answers the current watermark, or the zero time if no elements have
+	// been added.
+	Watermark() time.Time
+	// Flush answers every element held by the receiver and advances the watermark to
+	// the latest time added, so that elements added afterwards that are older than the
+	// flushed elements are late.
+	Flush() SortedRange
+}
+
+// NewWatermark answers a Watermark configured by the specified options.
+func NewWatermark(options WatermarkOptions) Watermark {
+	if options.Resolve == nil {
+		options.Resolve = LastWins
+	}
+	return &watermark{
+		options: options,
+	}
+}
+
+// watermark is the Watermark implementation. The held elements are kept as a sequence of
+// sorted runs, in the order in which they were added, in the same way as a log structured
+// merge tree: the elements of each call to Add become a new run, which is merged with the
+// runs before it while they are no more than twice its size. Each element is therefore copied
+// a logarithmic number of times, rather than each time elements are added, and there are only
+// a logarithmic number of runs. When the watermark advances, each run is partitioned at a
+// probe for the watermark and the parts before the watermark are merged as they are emitted.
+type watermark struct {
+	options WatermarkOptions
+	runs    []SortedRange // the held elements, which are newer than the watermark, oldest run first
+	latest  time.Time     // the latest time added
+	mark    time.Time     // the current watermark
+	started bool          // true once an element has been added
+}
+
+func (w *watermark) Add(elements []Element) SortedRange {
+	var late, held []Element
+	for _, e := range elements {
+		t := w.options.Time(e)
+		if t.Before(w.mark) {
+			late = append(late, e)
+			continue
+		}
+		if !w.started || t.After(w.latest) {
+			w.latest, w.started = t, true
+		}
+		held = append(held, e)
+	}
+
+	buffer := newMutableRange(w.options.Resolve)
+	buffer.Add(held)
+	w.hold(buffer.Freeze())
+
+	emitted := EmptyRange
+	if mark := w.latest.Add(-w.options.Lateness); mark.After(w.mark) {
+		w.mark = mark
+		emitted = w.emit(w.options.Probe(mark))
+	}
+
+	if late == nil || w.options.Policy == DropLate {
+		return useEmptyRangeIfEmpty(emitted)
+	}
+	lateRange := newMutableRange(w.options.Resolve)
+	lateRange.Add(late)
+	sorted := lateRange.Freeze()
+	if w.options.Late != nil {
+		w.options.Late(sorted)
+	}
+	if w.options.Policy == MergeLate {
+		emitted = MergeWith(sorted, emitted, w.options.Resolve)
+	}
+	return useEmptyRangeIfEmpty(emitted)
+}
+
+// hold adds the sorted elements of a call to Add to the held runs.
+func (w *watermark) hold(sorted SortedRange) {
+	if sorted.Limit() == 0 {
+		return
+	}
+	w.runs = append(w.runs, sorted)
+	for n := len(w.runs); n > 1 && w.runs[n-2].Limit() <= 2*w.runs[n-1].Limit(); n-- {
+		merged := Compact(MergeWith(w.runs[n-2], w.runs[n-1], w.options.Resolve))
+		w.runs = append(w.runs[0:n-2], merged)
+	}
+}
+
+// emit removes and answers the held elements that sort before the probe.
+func (w *watermark) emit(probe Element) SortedRange {
+	emitted := []SortedRange{}
+	runs := w.runs[0:0]
+	for _, r := range w.runs {
+		older, newer := r.Partition(probe, LessOrder)
+		if older.Limit() > 0 {
+			emitted = append(emitted, older)
+		}
+		if newer.Limit() > 0 {
+			runs = append(runs, newer)
+		}
+	}
+	w.runs = runs
+	return MergeAllWith(w.options.Resolve, emitted...)
+}
+
+func (w *watermark) Watermark() time.Time {
+	return w.mark
+}
+
+func (w *watermark) Flush() SortedRange {
+	flushed := MergeAllWith(w.options.Resolve, w.runs...)
+	w.runs = nil
+	if w.started {
+		w.mark = w.latest
+	}
+	return useEmptyRangeIfEmpty(flushed)
+}
@@ -0,0 +1,219 @@
+package tsl
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newSecondsWatermark answers a Watermark over intElements whose values are seconds
+// since the Unix epoch.
+func newSecondsWatermark(lateness int, policy LatePolicy, late func(SortedRange)) Watermark {
+	return NewWatermark(WatermarkOptions{
+		Lateness: time.Duration(lateness) * time.Second,
+		Time: func(e Element) time.Time {
+			return time.Unix(int64(e.(intElement).value), 0)
+		},
+		Probe: func(t time.Time) Element {
+			return intElement{int(t.Unix())}
+		},
+		Policy: policy,
+		Late:   late,
+	})
+}
+
+func Test_Watermark_Emit(t *testing.T) {
+	w := newSecondsWatermark(10, DropLate, nil)
+	if !w.Watermark().IsZero() {
+		t.Fatalf("initial watermark. got: %v, expected: zero", w.Watermark())
+	}
+
+	got := Elements(AsSlice(w.Add(NewElements([]int{5, 3, 12, 8}))))
+	expected := NewElements([]int{})
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("emitted before the watermark passes. got: %v, expected: %v", got, expected)
+	}
+	if !w.Watermark().Equal(time.Unix(2, 0)) {
+		t.Fatalf("watermark. got: %v, expected: %v", w.Watermark(), time.Unix(2, 0))
+	}
+
+	got = AsSlice(w.Add(NewElements([]int{20, 11})))
+	expected = NewElements([]int{3, 5, 8})
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("emitted once the watermark passes. got: %v, expected: %v", got, expected)
+	}
+
+	got = AsSlice(w.Flush())
+	expected = NewElements([]int{11, 12, 20})
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("flushed. got: %v, expected: %v", got, expected)
+	}
+	if w.Flush() != EmptyRange {
+		t.Fatalf("second flush should be empty")
+	}
+}
+
+func Test_Watermark_Bounded(t *testing.T) {
+	// elements that are at most 30 seconds late, in bursts of varying size
+	r := seededRand(t)
+	input := []int{}
+	distinct := map[int]bool{}
+	for i := 0; i < 5000; i++ {
+		input = append(input, i-r.Intn(30))
+		distinct[input[i]] = true
+	}
+
+	w := newSecondsWatermark(30, DropLate, nil)
+	got := Elements{}
+	for len(input) > 0 {
+		n := r.Intn(100) + 1
+		if n > len(input) {
+			n = len(input)
+		}
+		got = append(got, AsSlice(w.Add(NewElements(input[0:n])))...)
+		input = input[n:]
+	}
+	got = append(got, AsSlice(w.Flush())...)
+
+	if len(got) != len(distinct) {
+		t.Fatalf("emitted. got: %d, expected: %d", len(got), len(distinct))
+	}
+	for i := 1; i < len(got); i++ {
+		if !got[i-1].Less(got[i]) {
+			t.Fatalf("emitted out of order at %d. got: %v, expected: < %v", i, got[i-1], got[i])
+		}
+	}
+}
+
+func Test_Watermark_Late(t *testing.T) {
+	input := NewElements([]int{100, 80, 95, 50, 89, 110})
+	for _, policy := range []LatePolicy{DropLate, SideOutputLate, MergeLate} {
+		lateElements := Elements{}
+		w := newSecondsWatermark(10, policy, func(r SortedRange) {
+			lateElements = append(lateElements, AsSlice(r)...)
+		})
+
+		emitted := Elements{}
+		for _, e := range input {
+			emitted = append(emitted, AsSlice(w.Add([]Element{e}))...)
+		}
+		emitted = append(emitted, AsSlice(w.Flush())...)
+
+		var expected, expectedLate Elements
+		switch policy {
+		case DropLate:
+			expected, expectedLate = NewElements([]int{95, 100, 110}), Elements{}
+		case SideOutputLate:
+			expected, expectedLate = NewElements([]int{95, 100, 110}), NewElements([]int{80, 50, 89})
+		case MergeLate:
+			expected, expectedLate = NewElements([]int{80, 50, 89, 95, 100, 110}), NewElements([]int{80, 50, 89})
+		}
+		if !reflect.DeepEqual(emitted, expected) {
+			t.Fatalf("emitted with policy %d. got: %v, expected: %v", policy, emitted, expected)
+		}
+		if !reflect.DeepEqual(lateElements, expectedLate) {
+			t.Fatalf("late with policy %d. got: %v, expected: %v", policy, lateElements, expectedLate)
+		}
+	}
+}
+
+func Test_Watermark_AfterFlush(t *testing.T) {
+	w := newSecondsWatermark(60, DropLate, nil)
+	w.Add(NewElements([]int{10, 20}))
+	w.Flush()
+	got := AsSlice(w.Add(NewElements([]int{15, 20, 25})))
+	if len(got) != 0 {
+		t.Fatalf("emitted after flush. got: %v, expected: []", got)
+	}
+	expected := NewElements([]int{20, 25})
+	if got := AsSlice(w.Flush()); !reflect.DeepEqual(Elements(got), expected) {
+		t.Fatalf("elements older than a flush are late. got: %v, expected: %v", got, expected)
+	}
+}
+
+func Test_Watermark_Resolve(t *testing.T) {
+	w := NewWatermark(WatermarkOptions{
+		Time: func(e Element) time.Time {
+			return time.Unix(int64(e.(keyedElement).key), 0)
+		},
+		Probe: func(t time.Time) Element {
+			return keyedElement{key: int(t.Unix())}
+		},
+		Resolve: sum,
+	})
+	w.Add(keyed(1, 1, 2, 2))
+	got := AsSlice(w.Add(keyed(2, 20, 3, 3)))
+	expected := keyed(2, 22)
+	if !reflect.DeepEqual(Elements(got), expected) {
+		t.Fatalf("resolved. got: %v, expected: %v", got, expected)
+	}
+}
+
+// watermarkInput answers n elements that are each up to lateness seconds late.
+func watermarkInput(n int, lateness int) []Element {
+	r := rand.New(rand.NewSource(1))
+	input := make([]Element, n)
+	for i := range input {
+		input[i] = intElement{i - r.Intn(lateness)}
+	}
+	return input
+}
+
+func benchmarkWatermark(b *testing.B, lateness int) {
+	input := watermarkInput(1000000, lateness)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w := newSecondsWatermark(lateness, DropLate, nil)
+		n := 0
+		for j := 0; j < len(input); j += 1024 {
+			n += Count(w.Add(input[j:min(j+1024, len(input))]))
+		}
+		n += Count(w.Flush())
+	}
+}
+
+func Benchmark_Watermark_Lateness1000(b *testing.B) {
+	benchmarkWatermark(b, 1000)
+}
+
+func Benchmark_Watermark_Lateness100000(b *testing.B) {
+	benchmarkWatermark(b, 100000)
+}
+
+// benchmarkWatermarkSort sorts the input of a watermark benchmark in one piece, for comparison.
+func benchmarkWatermarkSort(b *testing.B, lateness int) {
+	input := watermarkInput(1000000, lateness)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		u := NewUnsortedRange()
+		for j := 0; j < len(input); j += 1024 {
+			u.Add(input[j:min(j+1024, len(input))])
+		}
+		Count(u.Freeze())
+	}
+}
+
+func Benchmark_Watermark_Sort_Lateness1000(b *testing.B) {
+	benchmarkWatermarkSort(b, 1000)
+}
+
+func Benchmark_Watermark_Sort_Lateness100000(b *testing.B) {
+	benchmarkWatermarkSort(b, 100000)
+}
+
+func Test_Watermark_Runs(t *testing.T) {
+	w := newSecondsWatermark(1000000, DropLate, nil).(*watermark)
+	input := watermarkInput(100000, 1000)
+	for j := 0; j < len(input); j += 100 {
+		w.Add(input[j : j+100])
+		if len(w.runs) > 12 {
+			t.Fatalf("the number of runs should be logarithmic. got: %d runs after %d elements", len(w.runs), j+100)
+		}
+	}
+	for i := 1; i < len(w.runs); i++ {
+		if w.runs[i-1].Limit() <= 2*w.runs[i].Limit() {
+			t.Fatalf("each run should be more than twice the size of the next. got: %d, %d", w.runs[i-1].Limit(), w.runs[i].Limit())
+		}
+	}
+}